@@ -0,0 +1,46 @@
+//go:build unix
+
+package daemonizer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// ProcessAlive reports whether a process with the given PID exists. On Unix
+// it probes with signal 0; a process owned by another user still counts as
+// alive.
+func ProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// probe checks p with signal 0. It returns os.ErrProcessDone if p is gone,
+// or has exited and only waits to be reaped, and any other error, e.g. EPERM
+// for another user's process, as is.
+func probe(p *os.Process) error {
+	err := p.Signal(syscall.Signal(0))
+	if errors.Is(err, syscall.ESRCH) || (err == nil && zombie(p.Pid)) {
+		return os.ErrProcessDone
+	}
+	return err
+}
+
+// zombie reports whether pid has exited but not been reaped, e.g. by an
+// init that is slow to reap orphans. It relies on /proc, and reports false
+// where that is missing.
+func zombie(pid int) bool {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	// the state follows the command name, which may itself hold ")"
+	i := bytes.LastIndexByte(stat, ')')
+	return i >= 0 && i+2 < len(stat) && stat[i+2] == 'Z'
+}
@@ -0,0 +1,41 @@
+//go:build windows
+
+package daemonizer
+
+import (
+	"os"
+	"syscall"
+)
+
+const (
+	processQueryLimitedInformation = 0x1000
+	stillActive                    = 259
+)
+
+// ProcessAlive reports whether a process with the given PID exists and has
+// not exited.
+func ProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		// access denied means the process exists
+		return err == syscall.ERROR_ACCESS_DENIED
+	}
+	defer syscall.CloseHandle(h)
+
+	var code uint32
+	if err := syscall.GetExitCodeProcess(h, &code); err != nil {
+		return false
+	}
+	return code == stillActive
+}
+
+// probe returns os.ErrProcessDone if p has exited.
+func probe(p *os.Process) error {
+	if !ProcessAlive(p.Pid) {
+		return os.ErrProcessDone
+	}
+	return nil
+}
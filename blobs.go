@@ -0,0 +1,153 @@
+package daemonizer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"time"
+)
+
+// SetBinaryParam attaches data to the params under key, for binary blobs
+// such as certificates or keys. Unlike a []byte in the JSON params, which
+// is base64-encoded, the bytes go to the daemon as they are, over a file of
+// their own: a sealed memfd with TransportMemfd on Linux, a pipe otherwise.
+// The daemon reads them with BinaryParam. A nil data removes key. The blobs
+// are sent with every Daemonize, Restart included, and count towards the
+// daemon's WithMaxParamBytes cap. data is copied. Called by the parent
+// process before Daemonize.
+func (d *Daemon) SetBinaryParam(key string, data []byte) {
+	if data == nil {
+		delete(d.blobs, key)
+		return
+	}
+	if d.blobs == nil {
+		d.blobs = make(map[string][]byte)
+	}
+	d.blobs[key] = bytes.Clone(data)
+}
+
+// BinaryParam returns the blob the parent attached under key with
+// SetBinaryParam, and whether there was one. It returns false before
+// WaitForParent. Called by the daemon process.
+func (d *Daemon) BinaryParam(key string) ([]byte, bool) {
+	data, ok := d.blobs[key]
+	return data, ok
+}
+
+// blobSpec describes one blob in the blob file; the blobs follow each
+// other in handoff order.
+type blobSpec struct {
+	Key  string `json:"key"`
+	Size int    `json:"size"`
+}
+
+// blobFeed is the parent's side of the blob file.
+type blobFeed struct {
+	r    *os.File // the daemon's end
+	w    *os.File // nil with a memfd, which already holds data
+	data []byte
+}
+
+// openBlobs puts d's blobs, in key order, into a file for the daemon, or
+// returns nils without blobs.
+func (d *Daemon) openBlobs(cfg *Config) ([]blobSpec, *blobFeed, error) {
+	if len(d.blobs) == 0 {
+		return nil, nil, nil
+	}
+
+	var specs []blobSpec
+	var buf bytes.Buffer
+	for _, key := range slices.Sorted(maps.Keys(d.blobs)) {
+		specs = append(specs, blobSpec{Key: key, Size: len(d.blobs[key])})
+		buf.Write(d.blobs[key])
+	}
+	if cfg != nil && cfg.Transport == TransportMemfd {
+		f, err := memfdParams(buf.Bytes())
+		if err == nil {
+			return specs, &blobFeed{r: f}, nil
+		}
+		d.logger.Debug("memfd unavailable for binary params, falling back to pipe", "error", err)
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, nil, pipeError("binary param pipe", err)
+	}
+	return specs, &blobFeed{r: r, w: w, data: buf.Bytes()}, nil
+}
+
+// attach passes the blob file after all other inherited files.
+func (b *blobFeed) attach(cmd *exec.Cmd) {
+	if b == nil {
+		return
+	}
+	cmd.ExtraFiles = append(cmd.ExtraFiles, b.r)
+}
+
+// send writes the blobs into the pipe from a goroutine, since they may not
+// fit into its buffer, and closes it after the last byte. It ends early if
+// the daemon exits without reading them. Call it once the daemon has
+// started.
+func (b *blobFeed) send() {
+	if b == nil {
+		return
+	}
+	b.r.Close()
+	b.r = nil
+	if b.w == nil {
+		return
+	}
+	w := b.w
+	b.w = nil
+	go func() {
+		defer w.Close()
+		w.Write(b.data)
+	}()
+}
+
+// release closes the parent's ends of the blob file, unless send took
+// them over.
+func (b *blobFeed) release() {
+	if b == nil {
+		return
+	}
+	closeIfOpen(b.r)
+	closeIfOpen(b.w)
+}
+
+// readBlobs reads the blobs described by h from fd h.BlobFd, and closes
+// it.
+func (d *Daemon) readBlobs(h handoff) (map[string][]byte, error) {
+	pollable(h.BlobFd)
+	f := os.NewFile(uintptr(h.BlobFd), "binary_params")
+	defer f.Close()
+
+	var total int64
+	for _, spec := range h.Blobs {
+		if spec.Size < 0 {
+			return nil, fmt.Errorf("binary param %q: negative size %d", spec.Key, spec.Size)
+		}
+		total += int64(spec.Size)
+	}
+	if total > d.maxParamBytes {
+		return nil, fmt.Errorf("%w: binary params of %d bytes", ErrParamsTooLarge, total)
+	}
+
+	f.SetReadDeadline(time.Now().Add(d.paramsTimeout))
+	blobs := make(map[string][]byte, len(h.Blobs))
+	for _, spec := range h.Blobs {
+		data := make([]byte, spec.Size)
+		if _, err := io.ReadFull(f, data); err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				err = ErrParamsTimeout
+			}
+			return nil, fmt.Errorf("binary param %q: %w", spec.Key, err)
+		}
+		blobs[spec.Key] = data
+	}
+	return blobs, nil
+}
@@ -0,0 +1,46 @@
+package daemonizer
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// setCapabilities reduces the calling process's permitted, effective, and
+// inheritable capability sets to caps and raises them as ambient, so they
+// also survive exec of the daemon's own children. The calls go to every
+// thread, since the kernel keeps capabilities per thread.
+func setCapabilities(caps []uintptr) error {
+	var data [2]unix.CapUserData
+	for _, c := range caps {
+		if c > unix.CAP_LAST_CAP {
+			return fmt.Errorf("unknown capability %d", c)
+		}
+		data[c/32].Permitted |= 1 << (c % 32)
+	}
+	for i := range data {
+		data[i].Effective = data[i].Permitted
+		data[i].Inheritable = data[i].Permitted
+	}
+
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	_, _, errno := syscall.AllThreadsSyscall(unix.SYS_CAPSET,
+		uintptr(unsafe.Pointer(&hdr)), uintptr(unsafe.Pointer(&data[0])), 0)
+	if errno == unix.ENOTSUP {
+		return fmt.Errorf("capset: %w (binaries built with cgo cannot change capabilities on all threads)", errno)
+	}
+	if errno != 0 {
+		return fmt.Errorf("capset: %w", errno)
+	}
+
+	for _, c := range caps {
+		_, _, errno := syscall.AllThreadsSyscall6(unix.SYS_PRCTL,
+			unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_RAISE, c, 0, 0, 0)
+		if errno != 0 {
+			return fmt.Errorf("raise ambient capability %d: %w", c, errno)
+		}
+	}
+	return nil
+}
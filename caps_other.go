@@ -0,0 +1,9 @@
+//go:build !linux
+
+package daemonizer
+
+import "errors"
+
+func setCapabilities(caps []uintptr) error {
+	return errors.New("capabilities are only supported on Linux")
+}
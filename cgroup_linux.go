@@ -0,0 +1,21 @@
+package daemonizer
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// joinCgroup moves the calling process into the cgroup v2 directory path.
+// cgroup.procs is never created, so a path that is not a cgroup fails.
+func joinCgroup(path string) error {
+	f, err := os.OpenFile(filepath.Join(path, "cgroup.procs"), os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(strconv.Itoa(os.Getpid())); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package daemonizer
+
+import "errors"
+
+func joinCgroup(path string) error {
+	return errors.New("cgroups are only supported on Linux")
+}
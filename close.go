@@ -0,0 +1,48 @@
+package daemonizer
+
+// Close releases the parent's hold on the daemon without stopping it: the
+// control channel and status pipe, the connection to the control socket,
+// and the process handle. The daemon keeps running, and sees the parent
+// hang up as if it had exited. Afterwards Daemonize, Stop, Kill, Restart,
+// Reload and Call return ErrClosed; Done still reports the daemon's exit.
+// Closing twice is harmless.
+//
+// A daemon that is the parent's own child is still waited for in the
+// background, so it does not linger as a zombie once it exits, and one that
+// is not, e.g. after a double fork, is watched until it is gone; its process
+// handle is released then.
+// Called by the parent process when it is done supervising the daemon.
+func (d *Daemon) Close() error {
+	if d.isDaemon {
+		return ErrNotParentProcess
+	}
+
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil
+	}
+	d.closed = true
+	ch, client := d.channel, d.client
+	d.client = nil
+	d.launched = nil
+	d.mu.Unlock()
+
+	if ch != nil {
+		ch.close()
+	}
+	if client != nil {
+		client.Close()
+	}
+	if d.cmd != nil {
+		d.reap()
+	}
+	return nil
+}
+
+// isClosed reports whether Close was called.
+func (d *Daemon) isClosed() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.closed
+}
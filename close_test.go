@@ -0,0 +1,87 @@
+//go:build unix
+
+package daemonizer_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+// openFds lists what the test binary's open fds refer to, e.g.
+// "pipe:[1234]". It needs /proc.
+func openFds(t *testing.T) []string {
+	t.Helper()
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skipf("cannot list open fds: %v", err)
+	}
+	var fds []string
+	for _, e := range entries {
+		// the fd ReadDir lists /proc/self/fd with is gone by now
+		if target, err := os.Readlink(filepath.Join("/proc/self/fd", e.Name())); err == nil {
+			fds = append(fds, target)
+		}
+	}
+	slices.Sort(fds)
+	return fds
+}
+
+// TestCloseReleasesFds checks that Close releases the parent's pipes while
+// the daemon keeps running, and its process handle once the daemon exits.
+func TestCloseReleasesFds(t *testing.T) {
+	before := openFds(t)
+	d := startDaemon(t, "serve", nil, &godaemonizer.Config{Control: true})
+	pid := d.PID()
+	t.Cleanup(func() { syscall.Kill(pid, syscall.SIGKILL) })
+
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	// a pidfd stays until the background wait reaps the daemon
+	if after := slices.DeleteFunc(openFds(t), func(fd string) bool {
+		return strings.Contains(fd, "pidfd")
+	}); !slices.Equal(after, before) {
+		t.Errorf("open fds after Close: %v, want %v", after, before)
+	}
+	if err := syscall.Kill(pid, 0); err != nil {
+		t.Errorf("daemon not running after Close: %v", err)
+	}
+	if err := d.Kill(); !errors.Is(err, godaemonizer.ErrClosed) {
+		t.Errorf("Kill after Close: %v, want ErrClosed", err)
+	}
+
+	syscall.Kill(pid, syscall.SIGKILL)
+	<-d.Done()
+	if after := openFds(t); !slices.Equal(after, before) {
+		t.Errorf("open fds after the daemon exited: %v, want %v", after, before)
+	}
+}
+
+// TestCloseDoubleFork checks that Done still closes once a daemon that is
+// not the parent's child exits after Close.
+func TestCloseDoubleFork(t *testing.T) {
+	d := startDaemon(t, "serve", nil, &godaemonizer.Config{DoubleFork: true})
+	pid := d.PID()
+	t.Cleanup(func() { syscall.Kill(pid, syscall.SIGKILL) })
+
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := d.Stop(time.Second); !errors.Is(err, godaemonizer.ErrClosed) {
+		t.Fatalf("Stop after Close: %v, want ErrClosed", err)
+	}
+	syscall.Kill(pid, syscall.SIGKILL)
+	select {
+	case <-d.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Done not closed after the daemon exited")
+	}
+}
@@ -0,0 +1,63 @@
+package daemonizer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// compressThreshold is the smallest params payload Config.CompressParams
+// compresses. Over a local pipe gzip saves bytes, not time: in
+// BenchmarkHandoffCompression a gzipped handoff shrinks about 6x but
+// takes 3-6x as long as a plain one below 16 KiB, and under twice as long
+// from 64 KiB up.
+const compressThreshold = 16 << 10
+
+// compress moves the params into ParamsGzip if cfg.CompressParams asks for
+// it and they are large enough to be worth it.
+func (h *handoff) compress(cfg *Config) error {
+	if cfg == nil || !cfg.CompressParams || len(h.Params) < compressThreshold {
+		return nil
+	}
+
+	return h.gzipParams()
+}
+
+// gzipParams moves the params into ParamsGzip, whatever their size.
+func (h *handoff) gzipParams() error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(h.Params); err != nil {
+		return fmt.Errorf("compress params: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("compress params: %w", err)
+	}
+	h.ParamsGzip = buf.Bytes()
+	h.Params = nil
+	return nil
+}
+
+// decompress restores params the parent compressed, unless they come to
+// more than limit bytes.
+func (h *handoff) decompress(limit int64) error {
+	if h.ParamsGzip == nil {
+		return nil
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(h.ParamsGzip))
+	if err != nil {
+		return fmt.Errorf("decompress params: %w", err)
+	}
+	params, err := io.ReadAll(io.LimitReader(zr, limit+1))
+	if err != nil {
+		return fmt.Errorf("decompress params: %w", err)
+	}
+	if int64(len(params)) > limit {
+		return fmt.Errorf("%w: more than %d bytes decompressed", ErrParamsTooLarge, limit)
+	}
+	h.Params = params
+	h.ParamsGzip = nil
+	return nil
+}
@@ -0,0 +1,102 @@
+package daemonizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+)
+
+// TestCompressRoundTrip checks that CompressParams gzips params from
+// compressThreshold up, and that they decode to the same bytes.
+func TestCompressRoundTrip(t *testing.T) {
+	cfg := &Config{CompressParams: true}
+	for _, size := range []int{compressThreshold - 1, compressThreshold, 1 << 20} {
+		payload, _ := json.Marshal(bytes.Repeat([]byte("x"), size))
+		h := handoff{Params: payload}
+		if err := h.compress(cfg); err != nil {
+			t.Fatal(err)
+		}
+		if gzipped := h.ParamsGzip != nil; gzipped != (len(payload) >= compressThreshold) {
+			t.Errorf("%d bytes of params: gzipped %v", len(payload), gzipped)
+		}
+		msg, err := json.Marshal(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got handoff
+		if err := json.Unmarshal(msg, &got); err != nil {
+			t.Fatal(err)
+		}
+		if err := got.decompress(defaultMaxParamBytes); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Params, payload) {
+			t.Errorf("%d bytes of params came back as %d different ones", len(payload), len(got.Params))
+		}
+	}
+}
+
+// BenchmarkHandoffCompression sends handoffs of JSON config around
+// compressThreshold through a pipe, as is and gzipped, and decodes them on
+// the other end, as the parent and the daemon would.
+func BenchmarkHandoffCompression(b *testing.B) {
+	for _, size := range []int{4 << 10, 16 << 10, 64 << 10, 1 << 20} {
+		params := make(map[string]string)
+		for i, n := 0, 0; n < size; i++ {
+			k, v := fmt.Sprintf("key%d", i), fmt.Sprintf("/var/lib/app/shard-%d/data", i)
+			params[k] = v
+			n += len(k) + len(v) + 6
+		}
+		payload, err := json.Marshal(params)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for _, gzipped := range []bool{false, true} {
+			name := fmt.Sprintf("%dKiB/plain", size>>10)
+			if gzipped {
+				name = fmt.Sprintf("%dKiB/gzip", size>>10)
+			}
+			b.Run(name, func(b *testing.B) {
+				b.SetBytes(int64(len(payload)))
+				h := handoff{Params: payload}
+				if gzipped {
+					h.gzipParams()
+				}
+				msg, err := json.Marshal(h)
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.ReportMetric(float64(len(msg)), "wire-B")
+				for range b.N {
+					r, w, err := os.Pipe()
+					if err != nil {
+						b.Fatal(err)
+					}
+					go func() {
+						defer w.Close()
+						h := handoff{Params: payload}
+						if gzipped {
+							h.gzipParams()
+						}
+						msg, _ := json.Marshal(h)
+						w.Write(msg)
+					}()
+					var h handoff
+					err = json.NewDecoder(r).Decode(&h)
+					io.Copy(io.Discard, r)
+					r.Close()
+					if err == nil {
+						err = h.decompress(defaultMaxParamBytes)
+					}
+					if err != nil || len(h.Params) != len(payload) {
+						b.Fatalf("got %d bytes of params (%v), want %d", len(h.Params), err, len(payload))
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package daemonizer_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+	"testing"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+func init() {
+	roles["param-sum"] = func(d *godaemonizer.Daemon) {
+		var params map[string]string
+		ready, err := d.WaitForParent(&params)
+		if err != nil {
+			os.Exit(1)
+		}
+		sum := sha256.Sum256([]byte(params["blob"]))
+		d.SetReadyData(hex.EncodeToString(sum[:]))
+		ready(nil)
+	}
+}
+
+// TestCompressParams checks that a large param reaches the daemon intact
+// with CompressParams.
+func TestCompressParams(t *testing.T) {
+	var b strings.Builder
+	for i := 0; b.Len() < 4<<20; i++ {
+		b.WriteString("line ")
+		b.WriteString(strings.Repeat("ab", i%50))
+		b.WriteByte('\n')
+	}
+	blob := b.String()
+	sum := sha256.Sum256([]byte(blob))
+
+	d := godaemonizer.New(godaemonizer.WithName("param-sum"))
+	t.Cleanup(func() { d.Kill() })
+	var got string
+	cfg := &godaemonizer.Config{CompressParams: true}
+	if err := d.DaemonizeWithData(context.Background(), map[string]string{"blob": blob}, cfg, &got); err != nil {
+		t.Fatalf("Daemonize: %v", err)
+	}
+	if want := hex.EncodeToString(sum[:]); got != want {
+		t.Errorf("daemon got params with sha256 %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,506 @@
+package daemonizer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The status pipe carries newline-delimited JSON messages: any number of
+// progress messages, then the startup report. With Config.Control or
+// Config.KeepOutputOpen set, the handshake pipes stay open after startup:
+// the param pipe carries requests from the parent, and the status pipe
+// carries replies and events from the daemon.
+
+type request struct {
+	ID     uint64          `json:"id"`
+	Method string          `json:"method"`
+	Args   json.RawMessage `json:"args,omitempty"`
+}
+
+// statusReader reads the daemon's messages from the status pipe. It skips
+// lines that are not JSON objects, such as a stray print to the wrong fd,
+// so they cannot fail the handshake.
+type statusReader struct {
+	r      *bufio.Reader
+	logger *slog.Logger
+}
+
+// maxLoggedLine caps how much of a skipped line is logged.
+const maxLoggedLine = 200
+
+func newStatusReader(r io.Reader, logger *slog.Logger) *statusReader {
+	return &statusReader{r: bufio.NewReader(r), logger: logger}
+}
+
+// Decode reads the next message into st.
+func (sr *statusReader) Decode(st *status) error {
+	for {
+		line, err := sr.r.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			var msg status
+			if line[0] == '{' && json.Unmarshal(line, &msg) == nil {
+				*st = msg
+				return nil
+			}
+			if len(line) > maxLoggedLine {
+				line = line[:maxLoggedLine]
+			}
+			sr.logger.Warn("skipping non-JSON daemon output", "line", string(line))
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// handler serves one control method in the daemon.
+type handler func(args json.RawMessage) (any, error)
+
+const (
+	methodCancel   = "cancel"
+	methodReload   = "reload"
+	methodShutdown = "shutdown" // control socket only
+	methodState    = "state"
+)
+
+var (
+	errOutputClosed    = errors.New("output channel not open")
+	errOutputAbandoned = errors.New("output no longer read")
+)
+
+// channel is the parent side of the pipes kept open after startup.
+type channel struct {
+	w     io.Writer // param pipe; nil without Control
+	r     io.Closer
+	dec   *statusReader
+	types StatusTypes
+
+	writeMu sync.Mutex
+	enc     *json.Encoder
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan status
+	err     error // why the reader stopped
+
+	output   chan json.RawMessage // nil without KeepOutputOpen
+	done     chan struct{}
+	quit     chan struct{} // closed once nobody will drain output
+	quitOnce sync.Once
+
+	shutdownAck atomic.Bool
+}
+
+// newChannel takes over the handshake pipes and starts reading the status
+// pipe. dec must be the reader that read the startup report, since it may
+// have buffered later messages.
+func newChannel(w io.Writer, r io.Closer, dec *statusReader, output bool, types StatusTypes) *channel {
+	c := &channel{
+		w:       w,
+		r:       r,
+		dec:     dec,
+		types:   types,
+		pending: make(map[uint64]chan status),
+		done:    make(chan struct{}),
+		quit:    make(chan struct{}),
+	}
+	if w != nil {
+		c.enc = json.NewEncoder(w)
+	}
+	if output {
+		c.output = make(chan json.RawMessage, 16)
+	}
+	go c.read()
+	return c
+}
+
+// read dispatches daemon messages until the status pipe is closed.
+func (c *channel) read() {
+	defer close(c.done)
+
+	for {
+		var msg status
+		if err := c.dec.Decode(&msg); err != nil {
+			c.stop(err)
+			return
+		}
+
+		switch msg.Type {
+		case c.types.Reply:
+			c.mu.Lock()
+			ch := c.pending[msg.ID]
+			delete(c.pending, msg.ID)
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- msg
+			}
+		case c.types.Shutdown:
+			c.shutdownAck.Store(true)
+		case c.types.Event:
+			if c.output != nil && !c.deliver(msg.Data) {
+				c.stop(errOutputAbandoned)
+				return
+			}
+		}
+	}
+}
+
+// deliver queues an event on the output channel. Once quit is closed it
+// gives up instead of waiting for a consumer that may never read again; it
+// reports whether the event was queued.
+func (c *channel) deliver(event json.RawMessage) bool {
+	select {
+	case c.output <- event:
+		return true
+	default:
+	}
+	select {
+	case c.output <- event:
+		return true
+	case <-c.quit:
+		return false
+	}
+}
+
+// abandon lets the reader stop once the output buffer is full, rather than
+// block forever and keep the status pipe open.
+func (c *channel) abandon() {
+	c.quitOnce.Do(func() {
+		close(c.quit)
+	})
+}
+
+// stop fails pending calls and closes the output channel.
+func (c *channel) stop(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.err = err
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+	if c.output != nil {
+		close(c.output)
+	}
+	c.r.Close()
+}
+
+// close closes both pipes, which ends the reader; the daemon sees the
+// parent hang up, as if it had exited.
+func (c *channel) close() {
+	if f, ok := c.w.(*os.File); ok {
+		closeWrite(f)
+	}
+	c.r.Close()
+	c.abandon()
+	<-c.done
+}
+
+// call sends a request and waits for its reply, decoding any reply data into
+// result (a pointer, or nil to discard it).
+func (c *channel) call(method string, args, result any) error {
+	if c.enc == nil {
+		return ErrNoControl
+	}
+
+	raw, err := json.Marshal(args)
+	if err != nil {
+		return fmt.Errorf("encode %s args: %w", method, err)
+	}
+
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return fmt.Errorf("send %s request: %w", method, c.err)
+	}
+	c.nextID++
+	id := c.nextID
+	ch := make(chan status, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	c.writeMu.Lock()
+	err = c.enc.Encode(request{ID: id, Method: method, Args: raw})
+	c.writeMu.Unlock()
+	if err != nil {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return fmt.Errorf("send %s request: %w", method, err)
+	}
+
+	rep, ok := <-ch
+	if !ok {
+		return fmt.Errorf("read %s reply: %w", method, c.err)
+	}
+	if rep.Error != "" {
+		return fmt.Errorf("%w: %s: %s", ErrRequestFailed, method, rep.Error)
+	}
+	if result != nil && len(rep.Data) > 0 {
+		if err := json.Unmarshal(rep.Data, result); err != nil {
+			return fmt.Errorf("decode %s reply: %w", method, err)
+		}
+	}
+	return nil
+}
+
+// Reload pushes new params to the daemon, which applies them with the
+// function registered by OnReload. It returns an error wrapping
+// ErrRequestFailed if the daemon rejects them. Requires Config.Control or
+// Config.ControlSocket. Called by the parent process.
+func (d *Daemon) Reload(params any) error {
+	c, err := d.control()
+	if err != nil {
+		return err
+	}
+	return c.call(methodReload, params, nil)
+}
+
+// Call invokes a method the daemon registered with RegisterMethod, passing
+// args (any JSON-serializable value) and decoding the daemon's result into
+// reply (a pointer, or nil to discard it). Calls are multiplexed over the
+// control channel, so several may be in flight at once. It returns an error
+// wrapping ErrRequestFailed if the method is unknown or its handler fails.
+// Requires Config.Control or Config.ControlSocket. Called by the parent
+// process.
+func (d *Daemon) Call(method string, args, reply any) error {
+	c, err := d.control()
+	if err != nil {
+		return err
+	}
+	return c.call(method, args, reply)
+}
+
+// control returns the channel that carries requests: the control channel,
+// or else a connection to the daemon's control socket.
+func (d *Daemon) control() (*channel, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return nil, ErrClosed
+	}
+	if d.channel != nil && d.channel.enc != nil {
+		return d.channel, nil
+	}
+
+	if d.controlSocket == "" {
+		return nil, ErrNoControl
+	}
+	if d.client == nil {
+		c, err := Dial(d.controlSocket)
+		if err != nil {
+			return nil, err
+		}
+		d.client = c
+	}
+	return d.client.ch, nil
+}
+
+// ackTimeout bounds how long Stop waits, after the daemon has exited, for
+// the reader to take in a shutdown ack still in the pipe.
+const ackTimeout = 100 * time.Millisecond
+
+// ShutdownAcked reports whether the daemon confirmed that its OnShutdown
+// hooks completed. The daemon can only send the confirmation with
+// Config.KeepOutputOpen or Config.Control set.
+// Called by the parent process, typically after Stop.
+func (d *Daemon) ShutdownAcked() bool {
+	return d.channel != nil && d.channel.shutdownAck.Load()
+}
+
+// awaitAck gives the reader a moment to drain the status pipe after the
+// daemon exited, in case the shutdown ack is still unread.
+func (d *Daemon) awaitAck() {
+	if d.channel == nil {
+		return
+	}
+	select {
+	case <-d.channel.done:
+	case <-time.After(ackTimeout):
+	}
+}
+
+// Output returns the events the daemon sends with SendEvent after startup.
+// The channel is closed when the daemon closes its end, e.g. by exiting.
+// It returns nil unless Config.KeepOutputOpen was set.
+// Called by the parent process.
+func (d *Daemon) Output() <-chan json.RawMessage {
+	if d.channel == nil || d.channel.output == nil {
+		return nil
+	}
+	return d.channel.output
+}
+
+// OnReload registers fn to apply params pushed by the parent's Reload. The
+// error fn returns, if any, is reported back to the parent. Register it
+// before calling ready. Called by the daemon process.
+func (d *Daemon) OnReload(fn func(params json.RawMessage) error) {
+	d.handle(methodReload, func(args json.RawMessage) (any, error) {
+		return nil, fn(args)
+	})
+}
+
+// Cancelled returns a channel that is closed if the parent gives up on the
+// startup, i.e. its Daemonize context is cancelled before ready, or if the
+// parent exits before ready, which closes its end of the param pipe. The
+// daemon should select on it during slow initialization, clean up, and exit
+// within Config.CancelGrace, after which the parent kills it. Only available
+// with the pipe and socketpair transports; it returns nil before
+// WaitForParent. Called by the daemon process.
+func (d *Daemon) Cancelled() <-chan struct{} {
+	return d.cancelled
+}
+
+// cancel closes Cancelled, once.
+func (d *Daemon) cancel() {
+	d.cancelOnce.Do(func() {
+		close(d.cancelled)
+	})
+}
+
+// SendEvent sends a JSON-serializable event to the parent, which receives it
+// from Output. It is only available after ready(nil), and only when the
+// parent set Config.KeepOutputOpen or Config.Control.
+// Called by the daemon process.
+func (d *Daemon) SendEvent(event any) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encode event: %w", err)
+	}
+
+	d.outMu.Lock()
+	defer d.outMu.Unlock()
+
+	if d.out == nil || !d.outReady {
+		return errOutputClosed
+	}
+	return d.out.Encode(status{Type: d.types.Event, OK: true, Data: data})
+}
+
+// RegisterMethod makes fn answer the parent's Call for method, e.g. for
+// status or stats queries. fn receives the raw JSON args and returns a
+// JSON-serializable result or an error, which is reported back to the
+// caller. Handlers run one at a time, in the order requests arrive.
+// Registering a method again replaces its handler; "reload" is the method
+// OnReload registers, "state" the one OnDumpState registers, and "cancel"
+// and "shutdown" are reserved. Register methods before calling ready.
+// Called by the daemon process.
+func (d *Daemon) RegisterMethod(method string, fn func(args json.RawMessage) (any, error)) {
+	if method == "" || method == methodCancel || method == methodShutdown {
+		panic(fmt.Sprintf("daemonizer: cannot register method %q", method))
+	}
+	d.handle(method, fn)
+}
+
+func (d *Daemon) handle(method string, h handler) {
+	d.handlerMu.Lock()
+	defer d.handlerMu.Unlock()
+
+	if d.handlers == nil {
+		d.handlers = make(map[string]handler)
+	}
+	d.handlers[method] = h
+}
+
+// send writes a message to the parent over the status pipe.
+func (d *Daemon) send(msg status) error {
+	d.outMu.Lock()
+	defer d.outMu.Unlock()
+
+	if d.out == nil {
+		return errOutputClosed
+	}
+	return d.out.Encode(msg)
+}
+
+// CloseOutput closes the daemon's end of the status pipe, which closes the
+// parent's Output channel. With Config.KeepOutputOpen the pipe otherwise
+// stays open until the daemon exits; closing it twice is harmless.
+// Called by the daemon process.
+func (d *Daemon) CloseOutput() {
+	d.closeOutput()
+}
+
+// closeOutput closes the daemon's end of the status pipe.
+func (d *Daemon) closeOutput() {
+	d.outMu.Lock()
+	defer d.outMu.Unlock()
+
+	if d.out != nil {
+		closeWrite(d.statusW)
+		d.out = nil
+	}
+}
+
+// serveControl answers parent requests until the parent closes its end. A
+// cancel request closes Cancelled and needs no reply. With a control channel
+// the parent closing its end also ends the status pipe.
+func (d *Daemon) serveControl(dec *json.Decoder, r *os.File, control bool) {
+	defer r.Close()
+
+	for {
+		var req request
+		if err := dec.Decode(&req); err != nil {
+			// a pipe closed before the report means the parent exited or
+			// gave up; a file transport simply has nothing more to read
+			if !d.reported.Load() && d.transport != transportMemfd && d.transport != transportFile {
+				d.cancel()
+			}
+			if control {
+				d.closeOutput()
+			}
+			return
+		}
+
+		if req.Method == methodCancel {
+			d.cancel()
+			continue
+		}
+		if err := d.send(d.serve(req)); err != nil {
+			return
+		}
+	}
+}
+
+func (d *Daemon) serve(req request) status {
+	d.handlerMu.Lock()
+	h := d.handlers[req.Method]
+	d.handlerMu.Unlock()
+
+	// the control channel and control socket clients take turns
+	d.serveMu.Lock()
+	defer d.serveMu.Unlock()
+
+	rep := status{Type: d.types.Reply, ID: req.ID}
+	if h == nil {
+		rep.Error = fmt.Sprintf("unknown method %q", req.Method)
+		return rep
+	}
+
+	result, err := h(req.Args)
+	if err != nil {
+		rep.Error = err.Error()
+		return rep
+	}
+	if result != nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			rep.Error = fmt.Sprintf("encode reply: %v", err)
+			return rep
+		}
+		rep.Data = data
+	}
+	rep.OK = true
+	return rep
+}
@@ -0,0 +1,148 @@
+package daemonizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// A control socket lets any process, not only the parent that started the
+// daemon, send it requests. It speaks the control channel's protocol, one
+// JSON request or reply per line, always with the default status types
+// since other clients cannot know the parent's.
+
+// controlSocketPath returns where the parent reaches cfg.ControlSocket. A
+// relative path is resolved against cfg.Dir, as the daemon resolves it.
+func controlSocketPath(cfg *Config) string {
+	if cfg == nil || cfg.ControlSocket == "" {
+		return ""
+	}
+	if filepath.IsAbs(cfg.ControlSocket) || cfg.Dir == "" {
+		return cfg.ControlSocket
+	}
+	return filepath.Join(cfg.Dir, cfg.ControlSocket)
+}
+
+// Client is a connection to a daemon's Config.ControlSocket. Like the
+// control channel, it multiplexes concurrent calls.
+type Client struct {
+	conn net.Conn
+	ch   *channel
+}
+
+// Dial connects to the daemon control socket at path.
+func Dial(path string) (*Client, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("dial control socket: %w", err)
+	}
+	dec := newStatusReader(conn, discardLogger)
+	return &Client{conn: conn, ch: newChannel(conn, conn, dec, false, defaultStatusTypes)}, nil
+}
+
+// Call invokes a method the daemon registered with RegisterMethod, like
+// (*Daemon).Call.
+func (c *Client) Call(method string, args, reply any) error {
+	return c.ch.call(method, args, reply)
+}
+
+// Reload pushes new params to the daemon, like (*Daemon).Reload.
+func (c *Client) Reload(params any) error {
+	return c.ch.call(methodReload, params, nil)
+}
+
+// Shutdown asks the daemon to shut down as if it received SIGTERM, running
+// its OnShutdown hooks. It returns once the daemon has accepted the
+// request, not once it has exited.
+func (c *Client) Shutdown() error {
+	return c.ch.call(methodShutdown, nil, nil)
+}
+
+// Close closes the connection; calls still in flight fail.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// listenControl starts serving the control socket at path. A stale socket
+// left by a daemon that died without cleaning up is replaced; a live one is
+// not.
+func (d *Daemon) listenControl(path string) error {
+	l, err := net.Listen("unix", path)
+	if err != nil && staleSocket(path) {
+		os.Remove(path)
+		l, err = net.Listen("unix", path)
+	}
+	if err != nil {
+		return err
+	}
+	d.controlLn = l
+	d.controlPath = path
+	go d.acceptControl(l)
+	return nil
+}
+
+// staleSocket reports whether path is a socket nobody listens on.
+func staleSocket(path string) bool {
+	fi, err := os.Lstat(path)
+	if err != nil || fi.Mode()&os.ModeSocket == 0 {
+		return false
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return true
+	}
+	conn.Close()
+	return false
+}
+
+// closeControl stops serving the control socket and removes it.
+func (d *Daemon) closeControl() {
+	if d.controlLn != nil {
+		d.controlLn.Close()
+	}
+}
+
+func (d *Daemon) acceptControl(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go d.serveConn(conn)
+	}
+}
+
+// serveConn answers one control socket client until it disconnects.
+func (d *Daemon) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	dec := json.NewDecoder(conn)
+	enc := json.NewEncoder(conn)
+	for {
+		var req request
+		if err := dec.Decode(&req); err != nil {
+			return
+		}
+
+		var rep status
+		if req.Method == methodShutdown {
+			rep = status{ID: req.ID, OK: true}
+		} else {
+			rep = d.serve(req)
+		}
+		rep.Type = defaultStatusTypes.Reply
+		if err := enc.Encode(rep); err != nil {
+			return
+		}
+
+		if req.Method == methodShutdown {
+			// the same path as a real SIGTERM, hooks and all
+			if p, err := os.FindProcess(os.Getpid()); err == nil {
+				p.Signal(syscall.SIGTERM)
+			}
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package daemonizer
+
+import (
+	"os"
+	"runtime/debug"
+)
+
+// setCrashOutput makes the runtime copy the report of a fatal panic or
+// error, from any goroutine, to the file at path, appending so earlier
+// crashes are kept.
+func setCrashOutput(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close() // SetCrashOutput keeps a duplicate
+	return debug.SetCrashOutput(f, debug.CrashOptions{})
+}
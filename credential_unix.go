@@ -0,0 +1,57 @@
+//go:build unix
+
+package daemonizer
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// RunAs makes the daemon run as the named user, with the user's primary
+// group and all of its supplementary groups, as a login would. Without the
+// supplementary groups a service user cannot read files that are only
+// readable through one of them. It sets SysProcAttr.Credential, on a copy of
+// SysProcAttr if one is already set. The parent needs the privilege to switch
+// users, normally root. To keep the parent's supplementary groups instead of
+// setting the user's, set NoSetGroups on the result, which mirrors the
+// syscall option.
+func (c *Config) RunAs(username string) error {
+	u, err := user.Lookup(username)
+	if err != nil {
+		return err
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return fmt.Errorf("user %s: uid %q: %w", username, u.Uid, err)
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return fmt.Errorf("user %s: gid %q: %w", username, u.Gid, err)
+	}
+	ids, err := u.GroupIds()
+	if err != nil {
+		return fmt.Errorf("user %s: groups: %w", username, err)
+	}
+	groups := make([]uint32, 0, len(ids))
+	for _, id := range ids {
+		g, err := strconv.ParseUint(id, 10, 32)
+		if err != nil {
+			return fmt.Errorf("user %s: gid %q: %w", username, id, err)
+		}
+		groups = append(groups, uint32(g))
+	}
+
+	attr := &syscall.SysProcAttr{}
+	if c.SysProcAttr != nil {
+		*attr = *c.SysProcAttr
+	}
+	attr.Credential = &syscall.Credential{
+		Uid:    uint32(uid),
+		Gid:    uint32(gid),
+		Groups: groups,
+	}
+	c.SysProcAttr = attr
+	return nil
+}
@@ -0,0 +1,104 @@
+//go:build unix
+
+package daemonizer_test
+
+import (
+	"context"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+// credentials is what the "whoami" daemon reports about itself.
+type credentials struct {
+	UID, GID int
+	Groups   []int
+}
+
+func init() {
+	roles["whoami"] = func(d *godaemonizer.Daemon) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		groups, err := os.Getgroups()
+		if err != nil {
+			ready(err)
+			return
+		}
+		d.SetReadyData(credentials{UID: os.Getuid(), GID: os.Getgid(), Groups: groups})
+		ready(nil)
+	}
+}
+
+// TestRunAs checks that the daemon runs as the user RunAs names, with the
+// user's primary and supplementary groups. It needs root, and a user
+// "nobody" that may run the test binary.
+func TestRunAs(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("switching users needs root")
+	}
+	u, err := user.Lookup("nobody")
+	if err != nil {
+		t.Skipf("no user to switch to: %v", err)
+	}
+	ids, err := u.GroupIds()
+	if err != nil {
+		t.Skipf("groups of %s: %v", u.Username, err)
+	}
+	var want credentials
+	want.UID, _ = strconv.Atoi(u.Uid)
+	want.GID, _ = strconv.Atoi(u.Gid)
+	for _, id := range ids {
+		g, _ := strconv.Atoi(id)
+		want.Groups = append(want.Groups, g)
+	}
+	slices.Sort(want.Groups)
+
+	letOthersRun(t, os.Args[0])
+
+	cfg := &godaemonizer.Config{}
+	if err := cfg.RunAs(u.Username); err != nil {
+		t.Fatalf("RunAs: %v", err)
+	}
+	d := godaemonizer.New(godaemonizer.WithName("whoami"))
+	t.Cleanup(func() { d.Kill() })
+	var got credentials
+	if err := d.DaemonizeWithData(context.Background(), nil, cfg, &got); err != nil {
+		t.Fatalf("Daemonize: %v", err)
+	}
+	slices.Sort(got.Groups)
+	if got.UID != want.UID || got.GID != want.GID || !slices.Equal(got.Groups, want.Groups) {
+		t.Errorf("daemon runs as %+v, want %+v", got, want)
+	}
+}
+
+// letOthersRun lets every user run the program at path, such as the test
+// binary in the go command's private build directory, until the test ends.
+func letOthersRun(t *testing.T, path string) {
+	t.Helper()
+	path, err := filepath.Abs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p := path; ; p = filepath.Dir(p) {
+		fi, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mode := fi.Mode().Perm(); mode&0o001 == 0 {
+			if err := os.Chmod(p, mode|0o001); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { os.Chmod(p, mode) })
+		}
+		if p == filepath.Dir(p) {
+			return
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package daemonizer
+
+import "errors"
+
+// RunAs is not supported on Windows.
+func (c *Config) RunAs(username string) error {
+	return errors.New("RunAs is not supported on Windows")
+}
@@ -1,46 +1,494 @@
 package daemonizer
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 )
 
 const daemonFlag = "--__daemon__"
 
+// debugFdsEnv, when set in the daemon's environment, makes WaitForParent dump
+// the state of fds 0-5 to stderr to diagnose fd inheritance problems.
+const debugFdsEnv = "DAEMONIZER_DEBUG_FDS"
+
+// defaultParentPollInterval is how often a daemon with DieWithParent checks
+// for its parent where the kernel cannot tell it.
+const defaultParentPollInterval = time.Second
+
+// defaultParamsTimeout bounds the daemon's wait for its params; see
+// WithParamsTimeout.
+const defaultParamsTimeout = 30 * time.Second
+
+// defaultMaxParamBytes caps the handoff the daemon accepts; see
+// WithMaxParamBytes.
+const defaultMaxParamBytes = 64 << 20
+
+// firstExtraFd is where Config.ExtraFiles start in the daemon, after the
+// param and status pipes.
+const firstExtraFd = 5
+
 var (
-	ErrAlreadyDaemon = errors.New("already running as daemon")
-	ErrDaemonFailed  = errors.New("daemon process failed to start")
+	ErrAlreadyDaemon        = errors.New("already running as daemon")
+	ErrDaemonFailed         = errors.New("daemon process failed to start")
+	ErrNotStarted           = errors.New("daemon not started")
+	ErrStopTimeout          = errors.New("daemon did not exit after SIGKILL")
+	ErrStopKilled           = errors.New("daemon killed after grace period")
+	ErrNotParentProcess     = errors.New("not the parent process")
+	ErrParamsTimeout        = errors.New("timed out waiting for params from parent")
+	ErrParamsTooLarge       = errors.New("params exceed the size limit")
+	ErrParamCorrupted       = errors.New("params corrupted in transfer")
+	ErrDaemonAlreadyRunning = errors.New("daemon already running")
+	ErrNoRestartSpec        = errors.New("restart needs the params and config of the original Daemonize")
+	ErrNotRestartable       = errors.New("config cannot start another daemon")
+	ErrParamsLossy          = errors.New("params do not survive JSON round trip")
+	ErrNoControl            = errors.New("control channel not enabled")
+	ErrRequestFailed        = errors.New("daemon request failed")
+	ErrDaemonAlreadyStarted = errors.New("daemon already started by this instance")
+	ErrStartTimeout         = errors.New("timed out launching daemon")
+	ErrNoSecrets            = errors.New("no secrets sent by parent")
+	ErrNoReadyFd            = errors.New("no readiness fd sent by parent")
+	ErrMissingDaemonPipes   = errors.New("daemon handshake pipes not inherited")
+	ErrBadFdInheritance     = errors.New("daemon handshake fds open in the wrong direction")
+	ErrStartupDeadline      = errors.New("daemon not ready by its startup deadline")
+	ErrInterrupted          = errors.New("interrupted by signal")
+	ErrReadParams           = errors.New("failed to read params from parent")
+	ErrClosed               = errors.New("daemonizer closed")
+	ErrExitedEarly          = errors.New("daemon exited before reading params")
+)
+
+// status is a message from the daemon on the status pipe. The first one is
+// the startup report; replies and events may follow (see control.go).
+type status struct {
+	Type    string          `json:"type,omitempty"`
+	ID      uint64          `json:"id,omitempty"`
+	PID     int             `json:"pid,omitempty"` // the daemon's own pid, in the startup report
+	OK      bool            `json:"ok"`
+	Error   string          `json:"error,omitempty"`
+	Code    int             `json:"code,omitempty"` // set with FailStartup
+	Message string          `json:"message,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Version int             `json:"version,omitempty"` // negotiated, in the startup report
+	Log     string          `json:"log,omitempty"`     // logLine or logEnd, from StartupLogWriter
+}
+
+// handoff is what the parent sends the daemon on fd 3: the user's params
+// and secrets plus the settings the daemon applies to itself during startup.
+type handoff struct {
+	Params      json.RawMessage   `json:"params"`
+	ParamsGzip  []byte            `json:"params_gzip,omitempty"` // Params, with Config.CompressParams
+	Secrets     json.RawMessage   `json:"secrets,omitempty"`
+	Control     bool              `json:"control,omitempty"`
+	KeepOutput  bool              `json:"keep_output,omitempty"`
+	Cgroup      string            `json:"cgroup,omitempty"`
+	Files       int               `json:"files,omitempty"`     // Config.ExtraFiles, from fd 5
+	Listeners   int               `json:"listeners,omitempty"` // Config.Listeners, after the files
+	Env         []string          `json:"env,omitempty"`       // the environment the parent intended
+	OOMAdj      *int              `json:"oom_score_adj,omitempty"`
+	ParentPID   int               `json:"parent_pid,omitempty"` // set with Config.DieWithParent
+	ParentPoll  time.Duration     `json:"parent_poll,omitempty"`
+	Types       *StatusTypes      `json:"status_types,omitempty"`
+	PIDFile     string            `json:"pid_file,omitempty"`
+	Caps        []uintptr         `json:"caps,omitempty"`
+	ControlSock string            `json:"control_socket,omitempty"`
+	Tail        *tailSpec         `json:"tail,omitempty"`
+	CrashFile   string            `json:"crash_file,omitempty"`
+	MaxProcs    int               `json:"gomaxprocs,omitempty"`
+	MaxThreads  int               `json:"max_threads,omitempty"`
+	Trace       map[string]string `json:"trace,omitempty"`
+	Detach      []int             `json:"detach,omitempty"` // fds lent for Config.PromptBeforeDetach
+	StatusFile  string            `json:"status_file,omitempty"`
+	StatusEvery time.Duration     `json:"status_every,omitempty"`
+	DoubleFork  bool              `json:"double_fork,omitempty"`
+	Relay       int               `json:"relay,omitempty"` // fd of the param pipe after a double fork
+	Notify      bool              `json:"sd_notify,omitempty"`
+	Version     int               `json:"version,omitempty"` // the parent's protocolVersion
+	Fsync       bool              `json:"fsync,omitempty"`
+	StdoutPath  string            `json:"stdout_path,omitempty"` // absolute, for ReopenLogs
+	StderrPath  string            `json:"stderr_path,omitempty"`
+	Deadline    time.Time         `json:"deadline,omitzero"` // Config.StartupDeadline, from the launch
+	ID          string            `json:"daemon_id,omitempty"`
+	Blobs       []blobSpec        `json:"blobs,omitempty"`   // see SetBinaryParam
+	BlobFd      int               `json:"blob_fd,omitempty"` // after all other inherited fds
+	StreamFd    int               `json:"stream,omitempty"`  // Config.ParamStream, after BlobFd
+	ReadyFd     int               `json:"ready,omitempty"`   // Config.ReadinessViaFd, last
+	Sum         string            `json:"sum,omitempty"`     // see checksum
+	State       []byte            `json:"state,omitempty"`   // see Config.TransferState
+}
+
+func newHandoff(params, secrets json.RawMessage, cfg *Config) handoff {
+	h := handoff{Params: params, Secrets: secrets, Env: os.Environ(), Version: protocolVersion}
+	if cfg != nil {
+		if cfg.Env != nil {
+			h.Env = cfg.Env
+		}
+		h.Control = cfg.Control
+		h.KeepOutput = cfg.KeepOutputOpen
+		h.Cgroup = cfg.CgroupPath
+		h.Files = len(cfg.ExtraFiles)
+		h.Listeners = len(cfg.Listeners)
+		h.OOMAdj = cfg.OOMScoreAdj
+		h.PIDFile = cfg.PIDFile
+		h.Types = cfg.StatusTypes
+		h.Caps = cfg.Capabilities
+		h.CrashFile = cfg.CrashFile
+		h.ControlSock = cfg.ControlSocket
+		if cfg.ParamFile != "" {
+			h.Env = nil // the environment must not end up on disk; see LaunchEnv
+		}
+		h.MaxProcs = cfg.GOMAXPROCS
+		h.MaxThreads = cfg.MaxThreads
+		h.Trace = cfg.TraceContext
+		h.Detach = promptFds(cfg)
+		h.StatusFile = cfg.StatusFile
+		h.StatusEvery = cfg.StatusFileInterval
+		h.DoubleFork = cfg.DoubleFork
+		h.Notify = cfg.SystemdNotify
+		h.Fsync = cfg.FsyncFiles
+		h.StdoutPath = absPath(cfg.StdoutPath)
+		h.StderrPath = absPath(cfg.StderrPath)
+		if cfg.StartupDeadline > 0 {
+			h.Deadline = time.Now().Add(cfg.StartupDeadline)
+		}
+		if h.StatusEvery <= 0 {
+			h.StatusEvery = defaultStatusFileInterval
+		}
+		if cfg.DieWithParent {
+			h.ParentPID = os.Getpid()
+			h.ParentPoll = cfg.ParentPollInterval
+			if h.ParentPoll <= 0 {
+				h.ParentPoll = defaultParentPollInterval
+			}
+		}
+	}
+	return h
+}
+
+// setup applies the daemon-side settings carried by h.
+func (h *handoff) setup() error {
+	// runtime settings first, so the rest of startup already runs under them
+	if h.MaxProcs > 0 {
+		runtime.GOMAXPROCS(h.MaxProcs)
+	}
+	if h.MaxThreads > 0 {
+		debug.SetMaxThreads(h.MaxThreads)
+	}
+	if h.CrashFile != "" {
+		if err := setCrashOutput(h.CrashFile); err != nil {
+			return fmt.Errorf("open crash file: %w", err)
+		}
+	}
+	if h.Tail != nil {
+		if err := h.Tail.schedule(); err != nil {
+			return fmt.Errorf("tail startup output: %w", err)
+		}
+	}
+	if h.Cgroup != "" {
+		if err := joinCgroup(h.Cgroup); err != nil {
+			return fmt.Errorf("join cgroup: %w", err)
+		}
+	}
+	if h.OOMAdj != nil {
+		if err := setOOMScoreAdj(*h.OOMAdj); err != nil {
+			return fmt.Errorf("set OOM score adjustment: %w", err)
+		}
+	}
+	if len(h.Caps) > 0 {
+		if err := setCapabilities(h.Caps); err != nil {
+			return fmt.Errorf("set capabilities: %w", err)
+		}
+	}
+	if h.ParentPID != 0 {
+		if err := watchParent(h.ParentPID, h.ParentPoll); err != nil {
+			return fmt.Errorf("watch parent: %w", err)
+		}
+	}
+	if h.PIDFile != "" {
+		if err := writePIDFile(h.PIDFile, h.Fsync); err != nil {
+			return fmt.Errorf("write PID file: %w", err)
+		}
+	}
+	return nil
+}
+
+// newStatus builds the report for ready(initErr), attaching readyData on
+// success.
+func newStatus(initErr error, readyData any) status {
+	var st status
+	if initErr == nil && readyData != nil {
+		data, err := json.Marshal(readyData)
+		if err != nil {
+			initErr = fmt.Errorf("encode ready data: %w", err)
+		}
+		st.Data = data
+	}
+
+	st.PID = os.Getpid()
+	st.OK = initErr == nil
+	if initErr != nil {
+		st.Error = initErr.Error()
+		st.Data = nil
+		var de *DaemonError
+		if errors.As(initErr, &de) {
+			st.Code = de.Code
+			st.Error = de.Message
+		}
+	}
+	return st
+}
+
+// DaemonError is the error Daemonize returns when the daemon reports a
+// failed startup. Code is the code the daemon passed to FailStartup, or 0.
+// It matches ErrDaemonFailed with errors.Is.
+type DaemonError struct {
+	Code    int
+	Message string
+}
+
+// FailStartup returns an error for ready that carries code to the parent,
+// where Daemonize returns it as a *DaemonError, e.g. to tell a port in use
+// from a bad config without matching on the message. Called by the daemon
+// process.
+func FailStartup(code int, message string) error {
+	return &DaemonError{Code: code, Message: message}
+}
+
+func (e *DaemonError) Error() string {
+	if e.Code != 0 {
+		return fmt.Sprintf("%v: %s (code %d)", ErrDaemonFailed, e.Message, e.Code)
+	}
+	return fmt.Sprintf("%v: %s", ErrDaemonFailed, e.Message)
+}
+
+func (e *DaemonError) Unwrap() error {
+	return ErrDaemonFailed
+}
+
+// daemonProcess records that this process was started as a daemon. It
+// outlives the marker, which New strips from os.Args, so that a Daemon
+// created later in the same process still refuses to daemonize again.
+var daemonProcess atomic.Bool
+
+var (
+	argsOnce    sync.Once
+	rewriteOnce sync.Once
+	launchArgs  []string // os.Args as first seen by New, marker included
 )
 
 type Config struct {
-	Dir    string
-	Env    []string
-	Stdin  *os.File
-	Stdout *os.File
-	Stderr *os.File
+	Dir        string
+	Env        []string
+	Stdin      *os.File
+	Stdout     *os.File
+	Stderr     *os.File
+	ParamCheck ParamCheck
+	Transport  Transport
+	Control    bool
+	CgroupPath string
+
+	KeepOutputOpen bool
+	OnProgress     func(message string)
+	StartTimeout   time.Duration
+	CancelGrace    time.Duration
+	SpawnRetries   int
+
+	Secrets        any
+	SensitiveKeys  []string
+	ExtraFiles     []*os.File
+	OOMScoreAdj    *int
+	Listeners      []net.Listener
+	WrapperCommand []string
+	Metrics        Metrics
+	DieWithParent  bool
+	PIDFile        string
+	CloseStdio     bool
+	StatusTypes    *StatusTypes
+	ParamSource    ParamSource
+	CreateDir      bool
+	DirMode        os.FileMode
+	Capabilities   []uintptr
+	CancelOnSignal bool
+	ParamFile      string
+	KeepParamFile  bool
+	CompressParams bool
+	CrashFile      string
+	PreStart       func(cmd *exec.Cmd) error
+	ControlSocket  string
+	AppendArgs     []string
+	StdoutPath     string
+	StderrPath     string
+	TailOutput     io.Writer
+	TailDuration   time.Duration
+	GOMAXPROCS     int
+	MaxThreads     int
+	TraceContext   map[string]string
+	SysProcAttr    *syscall.SysProcAttr
+	StdinData      []byte
+
+	PromptBeforeDetach bool
+	SanitizeParams     bool
+	ParentPollInterval time.Duration
+	StatusFile         string
+	StatusFileInterval time.Duration
+	DoubleFork         bool
+	ErrorHandler       func(message string) error
+	SystemdNotify      bool
+	FsyncFiles         bool
+	StartupDeadline    time.Duration
+	AllocatePTY        bool
+	Unshare            uintptr
+	StartupLog         io.Writer
+	ParamStream        io.Reader
+	ReadinessViaFd     bool
+	TransferState      bool
 }
 
 type Daemon struct {
 	args     []string
 	isDaemon bool
+	logger   *slog.Logger
+	marker   string
+	name     string // role of a named daemon; see WithName
+
+	preserveArgs bool
+	binary       string // program to run as the daemon; see ReExec
+
+	// parent side: the started daemon and its background reaper
+	mu       sync.Mutex
+	started  bool
+	closed   bool // see Close
+	cmd      *exec.Cmd
+	proc     *os.Process // the daemon as it reported itself
+	waitOnce sync.Once
+	exited   chan struct{}
+	waitErr  error
+	channel  *channel
+	launched *launchSpec // what Restart relaunches
+	state    []byte      // Config.TransferState, for the next launch
+	events   chan LifecycleEvent
+	pty      *os.File // master of Config.AllocatePTY, handed to the caller
+
+	controlSocket string  // Config.ControlSocket, as the parent reaches it
+	client        *Client // connection to it, made on first use
+
+	// both sides: how the handshake went, for TransportUsed,
+	// ProtocolVersion and Info
+	transport string
+	version   int
+	codec     string
+	id        string       // see DaemonID
+	logBase   *slog.Logger // the logger before it was tagged with id
+
+	// daemon side: data reported to the parent with readiness
+	rawParams json.RawMessage
+	secrets   json.RawMessage
+	blobs     map[string][]byte
+	stream    *os.File // Config.ParamStream
+	readyFd   *os.File // Config.ReadinessViaFd
+	readyOnce sync.Once
+	files     []*os.File
+	launchEnv []string
+	trace     map[string]string
+	onParams  func(params map[string]any) error
+	loadState func(state []byte) error
+	notify    bool      // Config.SystemdNotify
+	fsync     bool      // Config.FsyncFiles
+	logPaths  [2]string // Config.StdoutPath and StderrPath, for ReopenLogs
+	deadline  time.Time // Config.StartupDeadline
+
+	listenerFiles []*os.File
+	listenersOnce sync.Once
+	listeners     []net.Listener
+	listenersErr  error
+
+	shutdownMu      sync.Mutex
+	shutdownHooks   []func(ctx context.Context) error
+	hupMu           sync.Mutex
+	hupFn           func() error
+	hupSig          chan os.Signal // nil without hupFn
+	shutdownTimeout time.Duration
+	paramsTimeout   time.Duration
+	maxParamBytes   int64
+	readyData       any
+	readyMessage    string
+	isReady         atomic.Bool
+	outMu           sync.Mutex
+	pidFile         string
+	controlLn       net.Listener // Config.ControlSocket
+	controlPath     string       // where controlLn listens
+	types           StatusTypes  // message types chosen by the parent
+	paramR          *os.File
+	statusW         *os.File
+	out             *json.Encoder
+	outReady        bool // the startup report was sent and the pipe stays open
+	startLog        *startupLogWriter
+	handlerMu       sync.Mutex
+	serveMu         sync.Mutex
+	handlers        map[string]handler
+
+	statusMu     sync.Mutex
+	statusFile   string // Config.StatusFile, until shutdown
+	statusSince  time.Time
+	statusFields map[string]any
+
+	cancelled  chan struct{}
+	cancelOnce sync.Once
+	reported   atomic.Bool // the startup report is on its way
 }
 
-func New() *Daemon {
-	d := &Daemon{}
-	for _, arg := range os.Args {
-		if arg == daemonFlag {
+// New creates a Daemon for the current process. In the daemon it strips the
+// marker from os.Args (unless PreserveOSArgs is given), which is global
+// state: call New at the start of main, before starting goroutines that may
+// read os.Args. Doing so once is enough; later calls parse the same snapshot
+// and never touch os.Args again. The options are applied before the marker
+// is looked for, so WithMarker and WithName affect the detection.
+func New(opts ...Option) *Daemon {
+	d := &Daemon{
+		logger:          discardLogger,
+		marker:          daemonFlag,
+		shutdownTimeout: defaultShutdownTimeout,
+		paramsTimeout:   defaultParamsTimeout,
+		maxParamBytes:   defaultMaxParamBytes,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	argsOnce.Do(func() {
+		launchArgs = slices.Clone(os.Args)
+	})
+
+	for _, arg := range launchArgs {
+		if name, ok := d.parseMarker(arg); ok {
 			d.isDaemon = true
+			d.name = name
 		} else {
 			d.args = append(d.args, arg)
 		}
 	}
 	if d.isDaemon {
-		os.Args = d.args
+		daemonProcess.Store(true)
+	}
+	if d.isDaemon && !d.preserveArgs {
+		rewriteOnce.Do(func() {
+			os.Args = slices.Clone(d.args)
+		})
 	}
 	return d
 }
@@ -49,120 +497,1081 @@ func (d *Daemon) IsDaemon() bool {
 	return d.isDaemon
 }
 
+// Name returns the daemon's role: in the parent, the name given with
+// WithName; in the daemon, the name it was launched with. It is empty for
+// unnamed daemons.
+func (d *Daemon) Name() string {
+	return d.name
+}
+
+// markerArg is the argument the parent injects: the marker, followed by
+// "=name" for a named daemon.
+func (d *Daemon) markerArg() string {
+	if d.name == "" {
+		return d.marker
+	}
+	return d.marker + "=" + d.name
+}
+
+// parseMarker reports whether arg is a marker argument, and the daemon name
+// it carries, if any.
+func (d *Daemon) parseMarker(arg string) (name string, ok bool) {
+	if arg == d.marker {
+		return "", true
+	}
+	return strings.CutPrefix(arg, d.marker+"=")
+}
+
+// Args returns the command-line arguments with the daemon marker removed.
+// The returned slice is a copy and may be modified freely.
 func (d *Daemon) Args() []string {
-	return d.args
+	return slices.Clone(d.args)
+}
+
+// OriginalArgs returns the command-line arguments as the process was invoked
+// with them, before New stripped the marker, e.g. for re-executing the exact
+// same invocation. The returned slice is a copy and may be modified freely.
+func (d *Daemon) OriginalArgs() []string {
+	return slices.Clone(launchArgs)
 }
 
 // Daemonize launches the daemon process and waits for it to report readiness.
 // params must be JSON-serializable (e.g., a struct with json tags).
-// Called by the parent process.
+// Called by the parent process; check IsDaemon first, since a daemon must
+// never daemonize again. It is not supported on Windows; see RunService.
 func (d *Daemon) Daemonize(ctx context.Context, params any, cfg *Config) error {
-	if d.isDaemon {
-		return ErrAlreadyDaemon
+	return d.DaemonizeWithData(ctx, params, cfg, nil)
+}
+
+// DaemonizeWithData is like Daemonize, and additionally deserializes the
+// data the daemon attached with SetReadyData into data (a pointer, or nil
+// to discard it).
+func (d *Daemon) DaemonizeWithData(ctx context.Context, params any, cfg *Config, data any) error {
+	res, err := d.DaemonizeWithResult(ctx, params, cfg)
+	if err != nil {
+		return err
 	}
+	if data != nil && len(res.Data) > 0 {
+		if err := json.Unmarshal(res.Data, data); err != nil {
+			return fmt.Errorf("decode ready data: %w", err)
+		}
+	}
+	return nil
+}
 
-	paramR, paramW, err := os.Pipe()
+// DaemonizeResult is what a daemon reported with its readiness.
+type DaemonizeResult struct {
+	PID     int             // the daemon's process ID, as PID returns it
+	Message string          // set by the daemon with SetReadyMessage
+	Data    json.RawMessage // set by the daemon with SetReadyData; nil if none
+}
+
+// DaemonizeWithResult is like Daemonize, and additionally returns everything
+// the daemon reported with its readiness.
+func (d *Daemon) DaemonizeWithResult(ctx context.Context, params any, cfg *Config) (*DaemonizeResult, error) {
+	if d.isDaemon || daemonProcess.Load() {
+		return nil, ErrAlreadyDaemon
+	}
+
+	// one daemon per Daemon: a second call would orphan the first child.
+	// A failed attempt leaves nothing behind, so it may be retried.
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if d.started {
+		d.mu.Unlock()
+		return nil, ErrDaemonAlreadyStarted
+	}
+	d.started = true
+	d.mu.Unlock()
+
+	res, err := d.launch(ctx, params, cfg)
+	if err != nil && d.cmd == nil {
+		d.mu.Lock()
+		d.started = false
+		d.mu.Unlock()
+	}
 	if err != nil {
-		return fmt.Errorf("create param pipe: %w", err)
+		d.emit(LifecycleEvent{Kind: EventError, Err: err})
+	} else {
+		d.emit(LifecycleEvent{Kind: EventReady, PID: res.PID})
+	}
+	return res, err
+}
+
+// launch starts the daemon and runs the startup handshake.
+func (d *Daemon) launch(ctx context.Context, params any, cfg *Config) (*DaemonizeResult, error) {
+	if err := canReexec(); err != nil {
+		return nil, err
 	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	ctx, stop := interruptible(ctx, cfg)
+	defer stop()
 
-	statusR, statusW, err := os.Pipe()
+	params = d.sanitizeParams(params, cfg)
+	payload, err := json.Marshal(params)
 	if err != nil {
-		paramR.Close()
-		paramW.Close()
-		return fmt.Errorf("create status pipe: %w", err)
+		return nil, fmt.Errorf("encode params: %w", err)
+	}
+	if err := d.checkParams(params, payload, cfg); err != nil {
+		return nil, err
+	}
+	secrets, err := encodeSecrets(cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	cmd := exec.CommandContext(ctx, d.args[0], append([]string{daemonFlag}, d.args[1:]...)...)
-	cmd.ExtraFiles = []*os.File{paramR, statusW}
-	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	control := cfg != nil && cfg.Control
+	keepOutput := cfg != nil && cfg.KeepOutputOpen
+	h := newHandoff(payload, secrets, cfg)
+	h.ID = d.newDaemonID()
+	h.State, d.state = d.state, nil
+	h.Sum = h.checksum()
+	if err := h.compress(cfg); err != nil {
+		return nil, err
+	}
+	d.codec = h.codec()
+	if err := createDir(cfg); err != nil {
+		return nil, err
+	}
+	// the child gets its own copies; the parent's close once it started
+	stdout, stderr, err := openLogs(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer closeIfOpen(stdout)
+	defer closeIfOpen(stderr)
+	master, slave, err := openPTY(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer closeIfOpen(slave)
+	defer func() {
+		if d.pty != master {
+			closeIfOpen(master)
+		}
+	}()
+	finalOut, finalErr := stdout, stderr
+	if cfg != nil && finalOut == nil {
+		finalOut = cfg.Stdout
+	}
+	if cfg != nil && finalErr == nil {
+		finalErr = cfg.Stderr
+	}
+	tail, err := openTail(cfg, finalOut, finalErr)
+	if err != nil {
+		return nil, err
+	}
+	defer tail.release()
+	layout := d.layoutFds(cfg, [3]string{})
+	h.Tail = tail.spec(layout.tail)
+	if err := checkPIDFile(cfg); err != nil {
+		return nil, err
+	}
+	// the parent keeps its listeners; it only drops its duplicates once
+	// the child has inherited them
+	lfiles, err := listenerFiles(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFiles(lfiles)
+	blobSpecs, blobs, err := d.openBlobs(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer blobs.release()
+	stream, err := openParamStream(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.release()
+	h.Blobs, h.BlobFd, h.StreamFd = blobSpecs, layout.blob, layout.stream
+	readyPipe, err := openReadyPipe(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer readyPipe.release()
+	h.ReadyFd = layout.ready
+	msg, err := json.Marshal(h)
+	if err != nil {
+		return nil, fmt.Errorf("encode params: %w", err)
+	}
 
-	if cfg != nil {
-		cmd.Dir = cfg.Dir
-		cmd.Env = cfg.Env
-		cmd.Stdin = cfg.Stdin
-		cmd.Stdout = cfg.Stdout
-		cmd.Stderr = cfg.Stderr
+	paramR, paramW, statusR, statusW, err := d.openPipes(msg, cfg)
+	if err != nil {
+		return nil, err
 	}
+	defer removeParamFile(cfg)
 
-	if err := cmd.Start(); err != nil {
+	if err := ctx.Err(); err != nil {
 		paramR.Close()
-		paramW.Close()
+		closeWrite(paramW)
 		statusR.Close()
 		statusW.Close()
-		return fmt.Errorf("start daemon: %w", err)
+		return nil, err
+	}
+
+	var startTimeout time.Duration
+	var retries int
+	if cfg != nil {
+		startTimeout = cfg.StartTimeout
+		retries = cfg.SpawnRetries
+	}
+
+	build := func() (*exec.Cmd, error) {
+		cmd := d.command(cfg)
+		attachPipes(cmd, paramR, statusW, cfg, lfiles)
+		if stdout != nil {
+			cmd.Stdout = stdout
+		}
+		if stderr != nil {
+			cmd.Stderr = stderr
+		}
+		if slave != nil {
+			cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+		}
+		tail.attach(cmd)
+		blobs.attach(cmd)
+		stream.attach(cmd)
+		readyPipe.attach(cmd)
+		if cfg != nil && cfg.PreStart != nil {
+			if err := cfg.PreStart(cmd); err != nil {
+				return nil, fmt.Errorf("pre-start hook: %w", err)
+			}
+		}
+		return cmd, nil
+	}
+	m := metrics(cfg)
+	phase := time.Now()
+	cmd, err := start(build, retries, startTimeout, paramR, paramW, statusR, statusW)
+	if err != nil {
+		return nil, fmt.Errorf("start daemon: %w", namespaceError(unshareFlags(cfg), err))
 	}
+	m.ObserveSpawnDuration(time.Since(phase))
+	d.emit(LifecycleEvent{Kind: EventSpawned, PID: cmd.Process.Pid})
 
 	// close child-side ends now that the child has inherited them
 	paramR.Close()
 	statusW.Close()
+	closeIfOpen(slave)
+	closeStdio(cfg)
+	tail.copy()
+	blobs.send()
+	stream.send()
+	readyc := readyPipe.wait()
 
-	// send params
-	if err := json.NewEncoder(paramW).Encode(params); err != nil {
-		paramW.Close()
+	// send params, unless the transport already holds them. The param pipe
+	// stays open during the handshake to carry a cancel request, and after
+	// it with a control channel.
+	phase = time.Now()
+	if paramW != nil {
+		if startTimeout > 0 {
+			paramW.SetWriteDeadline(time.Now().Add(startTimeout))
+		}
+		if _, err := paramW.Write(msg); err != nil {
+			closeWrite(paramW)
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				// the child never read its params; don't leave it behind
+				statusR.Close()
+				cmd.Process.Kill()
+				cmd.Wait()
+				return nil, fmt.Errorf("send params: %w", ErrStartTimeout)
+			}
+			if brokenPipe(err) {
+				// a daemon that rejected its params, e.g. as too large,
+				// said why before it hung up
+				report := earlyReport(statusR, d.logger, statusTypes(cfg))
+				statusR.Close()
+				early := exitedEarly(cmd)
+				if report != nil {
+					return nil, report
+				}
+				return nil, fmt.Errorf("send params: %w", early)
+			}
+			statusR.Close()
+			cmd.Process.Release()
+			return nil, fmt.Errorf("send params: %w", err)
+		}
+		paramW.SetWriteDeadline(time.Time{})
+	}
+	m.ObserveHandshakeDuration(time.Since(phase))
+	d.emit(LifecycleEvent{Kind: EventParamsSent})
+
+	// wait for daemon to report status, or for ctx to give up on it
+	phase = time.Now()
+	var status status
+	dec := newStatusReader(statusR, d.logger)
+	startLog := newStartupLog(cfg)
+	defer startLog.end()
+	reportc := make(chan error, 1)
+	report := func() {
+		reportc <- readReport(dec, &status, statusTypes(cfg), func(message, log string) {
+			switch log {
+			case logEnd:
+				startLog.end()
+				return
+			case logLine:
+				startLog.line(message)
+			}
+			d.emit(LifecycleEvent{Kind: EventProgress, Message: message})
+			if cfg != nil && cfg.OnProgress != nil {
+				cfg.OnProgress(message)
+			}
+		})
+	}
+	// a context that can never be done needs no goroutine to race it, nor
+	// does a report that is the only sign of readiness
+	if ctx.Done() == nil && readyc == nil {
+		report()
+	} else {
+		go report()
+	}
+
+	// with a readiness fd the byte is the readiness; a report still ends
+	// the wait if it is a failure, and is used if it came first. st is the
+	// status to go by: the report, or, while the report goroutine still
+	// owns status, a plain success.
+	st := &status
+	pending, silent := false, false
+	// a readiness fd closed without the byte, e.g. by an exit
+	unsignalled := func() (*DaemonizeResult, error) {
+		closeWrite(paramW)
 		statusR.Close()
 		cmd.Process.Release()
-		return fmt.Errorf("send params: %w", err)
+		return nil, fmt.Errorf("%w: it closed its readiness fd without signalling", ErrDaemonFailed)
 	}
-	paramW.Close()
-
-	// wait for daemon to report status
-	var status struct {
-		OK    bool   `json:"ok"`
-		Error string `json:"error,omitempty"`
+wait:
+	for {
+		select {
+		case err = <-reportc:
+			reportc = nil
+			if h.ReadyFd == 0 || err != nil || !status.OK {
+				break wait
+			}
+			if silent {
+				return unsignalled()
+			}
+		case readyErr := <-readyc:
+			readyc = nil
+			if readyErr != nil {
+				if reportc == nil {
+					return unsignalled()
+				}
+				// the report, or the lack of one, tells why
+				silent = true
+				continue
+			}
+			if reportc == nil {
+				break wait
+			}
+			select {
+			case err = <-reportc:
+				reportc = nil
+			default:
+				// the daemon reports later; until it does, its pipes stay
+				// open, so that it sees neither a cancel nor EPIPE
+				early := newStatus(nil, nil)
+				early.PID = cmd.Process.Pid
+				st, pending = &early, true
+			}
+			break wait
+		case <-ctx.Done():
+			cancelStartup(cmd, paramW, cancelGrace(cfg))
+			closeWrite(paramW)
+			statusR.Close()
+			return nil, fmt.Errorf("daemon startup cancelled: %w", context.Cause(ctx))
+		}
+	}
+	if err == nil {
+		m.ObserveReadinessDuration(time.Since(phase))
+	}
+	if !control && !pending {
+		closeWrite(paramW)
 	}
 
-	if err := json.NewDecoder(statusR).Decode(&status); err != nil {
+	if err != nil {
+		closeWrite(paramW)
 		statusR.Close()
 		cmd.Process.Release()
-		return fmt.Errorf("read daemon status: %w", err)
+		return nil, fmt.Errorf("read daemon status: %w", err)
+	}
+	d.version = negotiateVersion(st.Version)
+
+	if !st.OK {
+		var err error = &DaemonError{Code: st.Code, Message: st.Error}
+		if cfg != nil && cfg.ErrorHandler != nil {
+			err = cfg.ErrorHandler(st.Error)
+		}
+		if err != nil {
+			closeWrite(paramW)
+			statusR.Close()
+			cmd.Process.Release()
+			return nil, err
+		}
+		// the handler accepted the failure; the daemon, if it keeps
+		// running, has closed its end of the pipes
+		closeWrite(paramW)
+		control, keepOutput = false, false
+	}
+
+	switch {
+	case pending:
+		go func() {
+			<-reportc
+			closeWrite(paramW)
+			statusR.Close()
+		}()
+	case control || keepOutput:
+		var requests io.Writer
+		if control {
+			requests = paramW
+		}
+		d.channel = newChannel(requests, statusR, dec, keepOutput, statusTypes(cfg))
+	default:
+		statusR.Close()
 	}
-	statusR.Close()
 
-	cmd.Process.Release()
+	// keep the process so the parent can stop or reap it later. The daemon
+	// reports its own pid, which differs from the child's if it re-executed
+	// or forked again before becoming ready; signals go to the real one.
+	d.exited = make(chan struct{})
+	d.cmd = cmd
+	d.proc = cmd.Process
+	d.launched = &launchSpec{params: payload, cfg: cfg}
+	d.controlSocket = controlSocketPath(cfg)
+	d.pty = master
+	// in a PID namespace of its own the daemon's pid is not the parent's
+	if st.PID != 0 && st.PID != cmd.Process.Pid && !ownPIDNamespace(unshareFlags(cfg)) {
+		d.logger.Debug("daemon reported a different pid", "child", cmd.Process.Pid, "daemon", st.PID)
+		if p, err := os.FindProcess(st.PID); err == nil {
+			d.proc = p
+		}
+	}
+
+	return &DaemonizeResult{PID: d.proc.Pid, Message: st.Message, Data: st.Data}, nil
+}
 
-	if !status.OK {
-		return fmt.Errorf("%w: %s", ErrDaemonFailed, status.Error)
+// unshareFlags returns cfg.Unshare, or 0 without cfg.
+func unshareFlags(cfg *Config) uintptr {
+	if cfg == nil {
+		return 0
+	}
+	return cfg.Unshare
+}
+
+// closeStdio closes the parent's copies of cfg.Stdout and cfg.Stderr if
+// cfg.CloseStdio asks for it, leaving the daemon as their only holder. The
+// parent's own standard streams are never closed.
+func closeStdio(cfg *Config) {
+	if cfg == nil || !cfg.CloseStdio {
+		return
+	}
+	for _, f := range []*os.File{cfg.Stdout, cfg.Stderr} {
+		if f != nil && f != os.Stdout && f != os.Stderr {
+			f.Close()
+		}
+	}
+}
+
+// defaultDirMode is the permission for a directory made by Config.CreateDir.
+const defaultDirMode os.FileMode = 0o755
+
+// createDir makes cfg.Dir, and any missing parents, if cfg.CreateDir is set.
+func createDir(cfg *Config) error {
+	if cfg == nil || !cfg.CreateDir || cfg.Dir == "" {
+		return nil
+	}
+	mode := cfg.DirMode
+	if mode == 0 {
+		mode = defaultDirMode
+	}
+	if err := os.MkdirAll(cfg.Dir, mode); err != nil {
+		return fmt.Errorf("create working directory: %w", err)
 	}
 	return nil
 }
 
+// start launches the command returned by build, retrying transient fork
+// failures but not build errors, and gives up after timeout if it is
+// positive. On failure the handshake files are closed; after a timeout that
+// happens only once the launch returns, since it may still be handing them
+// to the child, and a child that did start is killed and reaped.
+func start(build func() (*exec.Cmd, error), retries int, timeout time.Duration, files ...*os.File) (*exec.Cmd, error) {
+	closeAll := func() {
+		for _, f := range files {
+			closeIfOpen(f)
+		}
+	}
+
+	type result struct {
+		cmd *exec.Cmd
+		err error
+	}
+	resc := make(chan result, 1)
+	launch := func() {
+		cmd, err := startWithRetry(build, retries)
+		resc <- result{cmd, err}
+	}
+
+	if timeout <= 0 {
+		launch()
+	} else {
+		go launch()
+	}
+
+	var timerC <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timerC = timer.C
+	}
+
+	select {
+	case res := <-resc:
+		if res.err != nil {
+			closeAll()
+		}
+		return res.cmd, res.err
+	case <-timerC:
+		go func() {
+			if res := <-resc; res.err == nil {
+				res.cmd.Process.Kill()
+				res.cmd.Wait()
+			}
+			closeAll()
+		}()
+		return nil, ErrStartTimeout
+	}
+}
+
+// spawnBackoff is the pause before the first retry of a failed fork; it
+// doubles with each further attempt.
+const spawnBackoff = 10 * time.Millisecond
+
+// startWithRetry starts a fresh command from build, retrying up to retries
+// times with exponential backoff when the failure is transient resource
+// exhaustion (EAGAIN, ENOMEM). Other errors, such as ENOENT or EACCES, fail
+// immediately.
+func startWithRetry(build func() (*exec.Cmd, error), retries int) (*exec.Cmd, error) {
+	backoff := spawnBackoff
+	for attempt := 0; ; attempt++ {
+		cmd, err := build()
+		if err != nil {
+			return nil, err
+		}
+		err = cmd.Start()
+		if err == nil || attempt >= retries || !transientSpawnError(err) {
+			return cmd, err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func transientSpawnError(err error) bool {
+	return errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.ENOMEM)
+}
+
+// defaultCancelGrace is how long a cancelled startup may take to abort
+// before the daemon is killed.
+const defaultCancelGrace = time.Second
+
+func cancelGrace(cfg *Config) time.Duration {
+	if cfg != nil && cfg.CancelGrace > 0 {
+		return cfg.CancelGrace
+	}
+	return defaultCancelGrace
+}
+
+// cancelStartup asks a starting daemon to abort over the param pipe and
+// gives it grace to clean up and exit, then kills it. Without a param pipe
+// (memfd transport) it kills right away. Either way the child is reaped.
+func cancelStartup(cmd *exec.Cmd, paramW *os.File, grace time.Duration) {
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+
+	if paramW != nil {
+		if err := json.NewEncoder(paramW).Encode(request{Method: methodCancel}); err == nil {
+			timer := time.NewTimer(grace)
+			defer timer.Stop()
+			select {
+			case <-exited:
+				return
+			case <-timer.C:
+			}
+		}
+	}
+
+	cmd.Process.Kill()
+	<-exited
+}
+
+// promptFds returns the standard streams PromptBeforeDetach lends the
+// daemon until it is ready: stdin, and stderr unless it goes elsewhere.
+func promptFds(cfg *Config) []int {
+	if cfg == nil || !cfg.PromptBeforeDetach {
+		return nil
+	}
+	if cfg.Stderr != nil || cfg.StderrPath != "" {
+		return []int{0}
+	}
+	return []int{0, 2}
+}
+
+// exitedEarly reaps a child that stopped reading its params, usually
+// because it died, and describes how it ended. A child that closed its end
+// but is still running is killed after a short while.
+func exitedEarly(cmd *exec.Cmd) error {
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+
+	timer := time.NewTimer(killTimeout)
+	defer timer.Stop()
+	select {
+	case <-exited:
+	case <-timer.C:
+		cmd.Process.Kill()
+		<-exited
+	}
+	return fmt.Errorf("%w (%v)", ErrExitedEarly, cmd.ProcessState)
+}
+
+// earlyReport returns the failure a daemon that stopped reading its params
+// reported, if it did so within killTimeout, or nil.
+func earlyReport(statusR *os.File, logger *slog.Logger, types StatusTypes) error {
+	statusR.SetReadDeadline(time.Now().Add(killTimeout))
+	var st status
+	err := readReport(newStatusReader(statusR, logger), &st, types, func(string, string) {})
+	if err != nil || st.OK {
+		return nil
+	}
+	return &DaemonError{Code: st.Code, Message: st.Error}
+}
+
+// readReport reads messages until the startup report, handing progress
+// messages to progress, with their status.Log, and skipping any others.
+// A failure without a type is a report too: a daemon that could not read
+// its params does not know the custom StatusTypes they carry, so failParams
+// sends it with the default type.
+func readReport(dec *statusReader, st *status, types StatusTypes, progress func(message, log string)) error {
+	for {
+		if err := dec.Decode(st); err != nil {
+			return err
+		}
+		switch {
+		case st.Type == types.Report, st.Type == "" && !st.OK && st.Error != "":
+			return nil
+		case st.Type == types.Progress:
+			progress(st.Message, st.Log)
+		}
+	}
+}
+
+// command builds the daemon invocation, without the handshake pipes. It is
+// deliberately not tied to a context: the daemon must outlive Daemonize.
+func (d *Daemon) command(cfg *Config) *exec.Cmd {
+	args := append([]string{d.markerArg()}, d.args[1:]...)
+	if cfg != nil {
+		args = append(args, cfg.AppendArgs...)
+	}
+
+	bin := d.binary
+	if bin == "" {
+		bin = executable(d.args[0])
+	}
+
+	var cmd *exec.Cmd
+	if cfg != nil && len(cfg.WrapperCommand) > 0 {
+		// the wrapper runs the daemon by path, so argv[0] is the path too
+		wrapped := append(slices.Clone(cfg.WrapperCommand[1:]), bin)
+		cmd = exec.Command(cfg.WrapperCommand[0], append(wrapped, args...)...)
+	} else {
+		cmd = exec.Command(bin, args...)
+		cmd.Args[0] = d.args[0] // keep the name the program was invoked as
+	}
+	// the caller's attributes are the base; the daemon's own session is
+	// not negotiable
+	attr := &syscall.SysProcAttr{}
+	if cfg != nil && cfg.SysProcAttr != nil {
+		base := *cfg.SysProcAttr
+		attr = &base
+	}
+	newSession(attr)
+	if cfg != nil {
+		setNamespaces(attr, cfg.Unshare)
+	}
+	cmd.SysProcAttr = attr
+
+	if cfg != nil {
+		cmd.Dir = cfg.Dir
+		cmd.Env = cfg.Env
+		// a nil *os.File stored in the interface would leave the fd closed
+		// in the child, rather than connected to /dev/null
+		if cfg.Stdin != nil {
+			cmd.Stdin = cfg.Stdin
+		}
+		// exec copies the data into a pipe from a goroutine, and closes the
+		// pipe after the last byte
+		if cfg.StdinData != nil {
+			cmd.Stdin = bytes.NewReader(cfg.StdinData)
+		}
+		for _, fd := range promptFds(cfg) {
+			if fd == 0 {
+				cmd.Stdin = os.Stdin
+			} else {
+				cmd.Stderr = os.Stderr
+			}
+		}
+		if cfg.Stdout != nil {
+			cmd.Stdout = cfg.Stdout
+		}
+		if cfg.Stderr != nil {
+			cmd.Stderr = cfg.Stderr
+		}
+	}
+	return cmd
+}
+
+// executable returns the path of the running binary. argv[0] is only a
+// fallback: it may be a bare name found through PATH, or a relative path
+// that breaks once Config.Dir changes the child's working directory.
+func executable(argv0 string) string {
+	if path, err := os.Executable(); err == nil {
+		return path
+	}
+	return argv0
+}
+
 // WaitForParent receives params from the parent process and deserializes into dest.
-// dest must be a pointer to the type that was passed to Start.
+// dest must be a pointer to the type that was passed to Start, or nil to
+// decode RawParams later.
 // The returned function should be called to signal readiness (nil) or failure (error).
+// A failure to read the params, which wraps ErrReadParams, or to decode them
+// into dest is reported to the parent; if that fails too, the daemon writes
+// it to stderr and exits.
 func (d *Daemon) WaitForParent(dest any) (ready func(error), err error) {
 	if !d.isDaemon {
 		return nil, errors.New("not a daemon process")
 	}
 
-	paramR := os.NewFile(3, "param_pipe")
+	if os.Getenv(debugFdsEnv) != "" {
+		dumpFds(os.Stderr)
+	}
+
+	paramR, err := paramFile()
+	if err != nil {
+		return nil, fmt.Errorf("read params: %w", err)
+	}
 	statusW := os.NewFile(4, "status_pipe")
+	if err := checkPipes(paramR, statusW); err != nil {
+		// the fds may belong to someone else, such as the runtime's
+		// poller; their finalizers must never close them
+		rejectedFds = append(rejectedFds, paramR, statusW)
+		return nil, err
+	}
+	d.paramR = paramR
+	d.statusW = statusW
+	d.transport = transportOf(paramR)
+	d.out = json.NewEncoder(d.statusW)
 
-	if err := json.NewDecoder(paramR).Decode(dest); err != nil {
+	// a parent that died before sending params would leave the daemon
+	// waiting forever; memfds, which are regular files, never block
+	var h handoff
+	limit := &handoffLimit{r: paramR, n: d.maxParamBytes}
+	dec := json.NewDecoder(limit)
+	paramR.SetReadDeadline(time.Now().Add(d.paramsTimeout))
+	if err := dec.Decode(&h); err != nil {
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			err = ErrParamsTimeout
+		}
+		err = corrupted(err)
+		if limit.exceeded {
+			err = fmt.Errorf("%w: more than %d bytes", ErrParamsTooLarge, d.maxParamBytes)
+		}
+		return nil, d.failParams(paramR, fmt.Errorf("%w: %w", ErrReadParams, err))
+	}
+	paramR.SetReadDeadline(time.Time{})
+	// the limit is for the handoff; control requests may follow it
+	limit.n = -1
+	if h.DoubleFork {
+		d.forkAgain(dec, paramR, statusW, h)
+	}
+	// after a double fork the handoff came from the intermediate; requests
+	// from the parent still come over its param pipe
+	if h.Relay != 0 {
 		paramR.Close()
-		statusW.Close()
-		return nil, fmt.Errorf("read params: %w", err)
+		paramR = os.NewFile(uintptr(h.Relay), "param_pipe")
+		pollable(h.Relay)
+		d.paramR = paramR
+		d.transport = transportOf(paramR)
+		dec = json.NewDecoder(paramR)
 	}
-	paramR.Close()
+	d.codec = h.codec()
+	if err := h.decompress(d.maxParamBytes); err != nil {
+		return nil, d.failParams(paramR, fmt.Errorf("%w: %w", ErrReadParams, err))
+	}
+	if err := h.verify(); err != nil {
+		return nil, d.failParams(paramR, fmt.Errorf("%w: %w", ErrReadParams, err))
+	}
+	if h.BlobFd != 0 {
+		blobs, err := d.readBlobs(h)
+		if err != nil {
+			return nil, d.failParams(paramR, fmt.Errorf("%w: %w", ErrReadParams, err))
+		}
+		d.blobs = blobs
+	}
+	if h.StreamFd != 0 {
+		pollable(h.StreamFd)
+		d.stream = os.NewFile(uintptr(h.StreamFd), "param_stream")
+	}
+	if h.ReadyFd != 0 {
+		d.readyFd = os.NewFile(uintptr(h.ReadyFd), "ready_fd")
+	}
+	d.version = negotiateVersion(h.Version)
+	d.rawParams = h.Params
+	d.secrets = h.Secrets
+	d.launchEnv = h.Env
+	d.trace = h.Trace
+	d.tagLogger(h.ID)
+	d.types = defaultStatusTypes
+	if h.Types != nil {
+		d.types = *h.Types
+	}
+	for i := range h.Files {
+		d.files = append(d.files, os.NewFile(uintptr(firstExtraFd+i), fmt.Sprintf("extra_file_%d", i)))
+	}
+	for i := range h.Listeners {
+		fd := firstExtraFd + h.Files + i
+		d.listenerFiles = append(d.listenerFiles, os.NewFile(uintptr(fd), fmt.Sprintf("listener_%d", i)))
+	}
+	if dest != nil {
+		if err := json.Unmarshal(h.Params, dest); err != nil {
+			return nil, d.failParams(paramR, fmt.Errorf("decode params: %w", err))
+		}
+	}
+	// the param pipe carries a cancel request during startup, and control
+	// requests after it; serve it until the parent closes its end
+	d.cancelled = make(chan struct{})
+	go d.serveControl(dec, paramR, h.Control)
 
-	called := false
-	ready = func(initErr error) {
-		if called {
-			return
+	report := func(initErr error) {
+		d.closeStartupLog()
+		// let go of the terminal before the parent returns it to the shell
+		if len(h.Detach) > 0 {
+			if err := nullStdio(h.Detach); err != nil {
+				d.logger.Error("detach from terminal", "error", err)
+			}
 		}
-		called = true
-		defer statusW.Close()
 
-		status := struct {
-			OK    bool   `json:"ok"`
-			Error string `json:"error,omitempty"`
-		}{OK: initErr == nil}
+		st := newStatus(initErr, d.readyData)
+		st.Type = d.types.Report
+		st.Version = d.version
+		if st.OK {
+			st.Message = d.readyMessage
+		}
+		d.isReady.Store(st.OK)
+		d.reported.Store(true)
+		if !st.OK {
+			d.RemovePIDFile()
+			d.closeControl()
+			d.removeStatusFile()
+		} else if err := d.writeStatusFile(); err != nil {
+			d.logger.Error("write status file", "error", err)
+		}
+		sendErr := d.send(st)
+		if st.OK && d.notify {
+			d.notifyReady()
+		}
+		if sendErr == nil && st.OK && (h.Control || h.KeepOutput) {
+			d.outMu.Lock()
+			d.outReady = true
+			d.outMu.Unlock()
+			return
+		}
+		d.closeOutput()
+	}
+	var once sync.Once
+	ready = func(initErr error) {
+		once.Do(func() { report(initErr) })
+	}
+	if !h.Deadline.IsZero() {
+		d.deadline = h.Deadline
+		d.enforceDeadline(ready)
+	}
 
-		if initErr != nil {
-			status.Error = initErr.Error()
+	if d.name != "" {
+		title := filepath.Base(d.args[0]) + ": " + d.name
+		if err := SetProcessTitle(title); err != nil {
+			d.logger.Debug("set process title", "title", title, "error", err)
 		}
-		json.NewEncoder(statusW).Encode(status)
 	}
 
+	d.pidFile = h.PIDFile
+	d.notify = h.Notify
+	d.fsync = h.Fsync
+	d.logPaths = [2]string{h.StdoutPath, h.StderrPath}
+	if err := h.setup(); err != nil {
+		ready(err)
+		return nil, err
+	}
+	if h.ControlSock != "" {
+		if err := d.listenControl(h.ControlSock); err != nil {
+			err = fmt.Errorf("listen on control socket: %w", err)
+			ready(err)
+			return nil, err
+		}
+	}
+	if h.StatusFile != "" {
+		if err := d.startStatusFile(h.StatusFile, h.StatusEvery); err != nil {
+			err = fmt.Errorf("write status file: %w", err)
+			ready(err)
+			return nil, err
+		}
+	}
+	if len(h.State) > 0 && d.loadState != nil {
+		if err := d.loadState(h.State); err != nil {
+			err = fmt.Errorf("load state: %w", err)
+			ready(err)
+			return nil, err
+		}
+	}
+	if d.onParams != nil {
+		if err := d.runOnParams(h.Params); err != nil {
+			ready(err)
+			return nil, err
+		}
+	}
 	return ready, nil
 }
+
+// failParams reports a failure to get the params to the parent and returns
+// it. The parent may be gone by then, e.g. its death is what truncated the
+// params; since nobody would see the failure, the daemon then writes it to
+// stderr and exits with status 1.
+func (d *Daemon) failParams(paramR *os.File, err error) error {
+	paramR.Close()
+	st := newStatus(err, nil)
+	st.Type = d.types.Report // "" until the params are read; readReport knows
+	if sendErr := d.send(st); sendErr != nil {
+		fmt.Fprintf(os.Stderr, "daemonizer: %v (reporting it to the parent failed: %v)\n", err, sendErr)
+		os.Exit(1)
+	}
+	d.closeOutput()
+	return err
+}
+
+// PID returns the daemon's process ID as the daemon itself reported it
+// during the handshake, or 0 if no daemon was started.
+// Called by the parent process.
+func (d *Daemon) PID() int {
+	if d.proc == nil {
+		return 0
+	}
+	return d.proc.Pid
+}
+
+// rejectedFds keeps the files checkPipes rejected reachable, so that they
+// are never closed.
+var rejectedFds []*os.File
+
+// checkPipes makes sure the handshake fds are what the parent passes: a
+// pipe, socket or memfd for params and a pipe or socket for the status, so
+// that a daemon started by hand fails fast instead of blocking on a terminal
+// or decoding garbage.
+func checkPipes(params, status *os.File) error {
+	check := func(f *os.File, ok func(os.FileMode) bool) error {
+		fi, err := f.Stat()
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v (was the daemon marker passed by hand?)", ErrMissingDaemonPipes, f.Name(), err)
+		}
+		if anonymous(fi) {
+			return fmt.Errorf("%w: %s is an anonymous inode the process opened itself, so nothing was inherited there (was the daemon marker passed by hand?)", ErrMissingDaemonPipes, f.Name())
+		}
+		if !ok(fi.Mode()) {
+			return fmt.Errorf("%w: %s is a %s (was the daemon marker passed by hand?)", ErrMissingDaemonPipes, f.Name(), modeName(fi.Mode()))
+		}
+		return nil
+	}
+	isPipe := func(m os.FileMode) bool { return m&(os.ModeNamedPipe|os.ModeSocket) != 0 }
+
+	if err := check(params, func(m os.FileMode) bool { return isPipe(m) || m.IsRegular() }); err != nil {
+		return err
+	}
+	if err := check(status, isPipe); err != nil {
+		return err
+	}
+
+	// the types match with the two fds swapped, e.g. by a wrapper that
+	// remapped them; the access modes tell
+	if readable, _ := accessMode(params); !readable {
+		return fmt.Errorf("%w: %s is not readable (fd 3 must be the read end of the param pipe; was it swapped with fd 4?)", ErrBadFdInheritance, params.Name())
+	}
+	if _, writable := accessMode(status); !writable {
+		return fmt.Errorf("%w: %s is not writable (fd 4 must be the write end of the status pipe; was it swapped with fd 3?)", ErrBadFdInheritance, status.Name())
+	}
+	return nil
+}
+
+// modeName describes a file type for error messages.
+func modeName(m os.FileMode) string {
+	switch {
+	case m.IsRegular():
+		return "regular file"
+	case m&os.ModeCharDevice != 0:
+		return "character device"
+	case m&os.ModeDevice != 0:
+		return "block device"
+	case m.IsDir():
+		return "directory"
+	case m&os.ModeSocket != 0:
+		return "socket"
+	default:
+		return "file of type " + m.Type().String()
+	}
+}
+
+// ExtraFiles returns the files the parent passed in Config.ExtraFiles, in
+// order, e.g. an inherited listening socket for net.FileListener. It returns
+// nil before WaitForParent. Called by the daemon process.
+func (d *Daemon) ExtraFiles() []*os.File {
+	return d.files
+}
+
+// RawParams returns the params exactly as the parent serialized them, for
+// custom decoding (e.g. with json.Decoder.UseNumber). It returns nil before
+// WaitForParent. Called by the daemon process.
+func (d *Daemon) RawParams() json.RawMessage {
+	return d.rawParams
+}
+
+// ReportProgress sends a progress message to the parent while the daemon is
+// still starting up; the parent receives it through Config.OnProgress before
+// the readiness report. The message is in the pipe when ReportProgress
+// returns; nothing is buffered. Called by the daemon process, before ready.
+func (d *Daemon) ReportProgress(message string) error {
+	return d.send(status{Type: d.types.Progress, Message: message})
+}
+
+// SetReadyData attaches JSON-serializable data to the daemon's readiness
+// report, e.g. the address of a listener bound to port 0. The parent receives
+// it through DaemonizeWithData. Must be called before ready(nil).
+func (d *Daemon) SetReadyData(data any) {
+	d.readyData = data
+}
+
+// SetReadyMessage attaches a human-readable message to the daemon's
+// readiness report, e.g. "listening on :8080". The parent receives it
+// through DaemonizeWithResult. Must be called before ready(nil).
+func (d *Daemon) SetReadyMessage(message string) {
+	d.readyMessage = message
+}
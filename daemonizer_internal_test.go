@@ -0,0 +1,27 @@
+package daemonizer
+
+import (
+	"os"
+	"testing"
+)
+
+// TestCommandBareName checks that a program found through PATH, whose
+// argv[0] is a bare name, is run by its path but keeps the name.
+func TestCommandBareName(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Skip(err)
+	}
+	d := New()
+	d.args = append([]string{"mycli"}, d.args[1:]...)
+	cmd := d.command(nil)
+	if cmd.Err != nil {
+		t.Fatal(cmd.Err)
+	}
+	if cmd.Path != exe {
+		t.Errorf("Path = %q, want %q", cmd.Path, exe)
+	}
+	if cmd.Args[0] != "mycli" {
+		t.Errorf("argv[0] = %q, want %q", cmd.Args[0], "mycli")
+	}
+}
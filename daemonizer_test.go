@@ -0,0 +1,353 @@
+package daemonizer_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+func init() {
+	roles["redaemonize"] = func(d *godaemonizer.Daemon) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		// a Daemon created later in the daemon, without the marker in
+		// os.Args any more, must refuse as well
+		for _, dm := range []*godaemonizer.Daemon{d, godaemonizer.New()} {
+			if err := dm.Daemonize(context.Background(), nil, nil); !errors.Is(err, godaemonizer.ErrAlreadyDaemon) {
+				ready(fmt.Errorf("Daemonize in the daemon: %v, want %v", err, godaemonizer.ErrAlreadyDaemon))
+				return
+			}
+		}
+		ready(nil)
+	}
+	roles["exit-now"] = func(*godaemonizer.Daemon) { os.Exit(2) }
+	roles["runtime"] = func(d *godaemonizer.Daemon) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		maxThreads := debug.SetMaxThreads(10000)
+		debug.SetMaxThreads(maxThreads)
+		d.SetReadyData([]int{runtime.GOMAXPROCS(0), maxThreads})
+		ready(nil)
+	}
+	roles["worker"] = reportRole
+	roles["scheduler"] = reportRole
+	roles["args"] = func(d *godaemonizer.Daemon) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		d.SetReadyData(os.Args)
+		ready(nil)
+	}
+	roles["missing-pipes"] = func(d *godaemonizer.Daemon) {
+		_, err := d.WaitForParent(nil)
+		fmt.Fprintln(os.Stderr, err)
+		if !errors.Is(err, godaemonizer.ErrMissingDaemonPipes) {
+			os.Exit(1)
+		}
+		os.Exit(5)
+	}
+	roles["swapped-fds"] = func(d *godaemonizer.Daemon) {
+		_, err := d.WaitForParent(nil)
+		fmt.Fprintln(os.Stderr, err)
+		if !errors.Is(err, godaemonizer.ErrBadFdInheritance) {
+			os.Exit(1)
+		}
+		os.Exit(5)
+	}
+	roles["params-timeout"] = func(*godaemonizer.Daemon) {
+		d := godaemonizer.New(godaemonizer.WithParamsTimeout(100 * time.Millisecond))
+		if _, err := d.WaitForParent(nil); !errors.Is(err, godaemonizer.ErrParamsTimeout) {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(5)
+	}
+	roles["concurrent-new"] = func(d *godaemonizer.Daemon) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		ready(concurrentNew(d.Args(), true))
+	}
+}
+
+// concurrentNew creates Daemons from several goroutines at once, each
+// also reading os.Args, and checks that they all see args.
+func concurrentNew(args []string, isDaemon bool) error {
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = len(os.Args)
+			d := godaemonizer.New()
+			if d.IsDaemon() != isDaemon || !slices.Equal(d.Args(), args) {
+				errs[i] = fmt.Errorf("New in goroutine %d: IsDaemon %v, Args %q", i, d.IsDaemon(), d.Args())
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// TestDaemonizeInDaemon checks that a daemon cannot daemonize again.
+func TestDaemonizeInDaemon(t *testing.T) {
+	startDaemon(t, "redaemonize", nil, nil)
+}
+
+// TestConcurrentNew checks that New may be called from any goroutine, in
+// the parent and in the daemon. Run it with -race.
+func TestConcurrentNew(t *testing.T) {
+	if err := concurrentNew(os.Args, false); err != nil {
+		t.Error(err)
+	}
+	startDaemon(t, "concurrent-new", nil, nil)
+}
+
+// TestDaemonizeTwice checks that a second Daemonize on the same Daemon is
+// refused and leaves the first daemon in place.
+func TestDaemonizeTwice(t *testing.T) {
+	d := startDaemon(t, "serve", nil, nil)
+	pid := d.PID()
+	if err := d.Daemonize(context.Background(), nil, nil); !errors.Is(err, godaemonizer.ErrDaemonAlreadyStarted) {
+		t.Fatalf("second Daemonize: %v, want %v", err, godaemonizer.ErrDaemonAlreadyStarted)
+	}
+	if d.PID() != pid {
+		t.Errorf("PID changed from %d to %d", pid, d.PID())
+	}
+	select {
+	case <-d.Done():
+		t.Error("the first daemon exited")
+	default:
+	}
+}
+
+// TestMarkerWithoutPipes checks that a program run with the daemon marker
+// by hand, without the handshake pipes, fails at once with
+// ErrMissingDaemonPipes.
+func TestMarkerWithoutPipes(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, os.Args[0], "--__daemon__=missing-pipes")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) || exit.ExitCode() != 5 {
+		t.Fatalf("run with the marker: %v, stderr %q; want ErrMissingDaemonPipes", err, stderr.String())
+	}
+}
+
+// TestMarkerWithStdinRedirected checks that a daemon run by hand with
+// stdin redirected from a file, which holds a valid handoff, still fails
+// with ErrMissingDaemonPipes rather than reading its params from there.
+func TestMarkerWithStdinRedirected(t *testing.T) {
+	stdin := filepath.Join(t.TempDir(), "params.json")
+	if err := os.WriteFile(stdin, []byte(`{"params":{"n":1}}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(stdin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, os.Args[0], "--__daemon__=missing-pipes")
+	cmd.Stdin = f
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) || exit.ExitCode() != 5 {
+		t.Fatalf("run with the marker and stdin from a file: %v, stderr %q; want ErrMissingDaemonPipes", err, stderr.String())
+	}
+}
+
+// TestSwappedFds checks that a daemon whose param and status pipes come in
+// on each other's fds fails with ErrBadFdInheritance rather than hanging or
+// failing to decode.
+func TestSwappedFds(t *testing.T) {
+	paramR, paramW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer paramW.Close()
+	statusR, statusW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer statusR.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, os.Args[0], "--__daemon__=swapped-fds")
+	cmd.ExtraFiles = []*os.File{statusW, paramR}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) || exit.ExitCode() != 5 {
+		t.Fatalf("run with swapped fds: %v, stderr %q; want ErrBadFdInheritance", err, stderr.String())
+	}
+}
+
+// TestParamsTimeout checks that a daemon whose parent starts it but never
+// sends the params gives up after its params timeout, and reports why.
+func TestParamsTimeout(t *testing.T) {
+	paramR, paramW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer paramW.Close()
+	statusR, statusW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer statusR.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, os.Args[0], "--__daemon__=params-timeout")
+	cmd.ExtraFiles = []*os.File{paramR, statusW}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	err = cmd.Start()
+	paramR.Close()
+	statusW.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, _ := io.ReadAll(statusR)
+	err = cmd.Wait()
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) || exit.ExitCode() != 5 {
+		t.Fatalf("daemon without params: %v, stderr %q; want ErrParamsTimeout", err, stderr.String())
+	}
+	if !strings.Contains(string(report), godaemonizer.ErrParamsTimeout.Error()) {
+		t.Errorf("status %q does not report %q", report, godaemonizer.ErrParamsTimeout)
+	}
+}
+
+// roleReport is what the named roles report: who they are and what they
+// were given.
+type roleReport struct {
+	Name   string         `json:"name"`
+	Params map[string]any `json:"params"`
+}
+
+func reportRole(d *godaemonizer.Daemon) {
+	var params map[string]any
+	ready, err := d.WaitForParent(&params)
+	if err != nil {
+		os.Exit(1)
+	}
+	d.SetReadyData(roleReport{Name: d.Name(), Params: params})
+	ready(nil)
+	select {}
+}
+
+// TestNamedDaemons checks that daemons of one binary started with
+// different names each run their own role with their own params.
+func TestNamedDaemons(t *testing.T) {
+	for _, name := range []string{"worker", "scheduler"} {
+		d := godaemonizer.New(godaemonizer.WithName(name))
+		t.Cleanup(func() { d.Kill() })
+		var got roleReport
+		if err := d.DaemonizeWithData(context.Background(), map[string]any{"for": name}, nil, &got); err != nil {
+			t.Fatalf("Daemonize %s: %v", name, err)
+		}
+		if got.Name != name || got.Params["for"] != name {
+			t.Errorf("daemon %s reported %+v", name, got)
+		}
+	}
+}
+
+// TestAppendArgs checks that Config.AppendArgs end up at the end of the
+// daemon's os.Args.
+func TestAppendArgs(t *testing.T) {
+	d := godaemonizer.New(godaemonizer.WithName("args"))
+	t.Cleanup(func() { d.Kill() })
+	extra := []string{"--worker-id=7", "--internal-mode"}
+	var args []string
+	if err := d.DaemonizeWithData(context.Background(), nil, &godaemonizer.Config{AppendArgs: extra}, &args); err != nil {
+		t.Fatalf("Daemonize: %v", err)
+	}
+	if want := append(d.Args(), extra...); !slices.Equal(args, want) {
+		t.Errorf("daemon args %q, want %q", args, want)
+	}
+}
+
+// TestRuntimeLimits checks that the daemon runs with the GOMAXPROCS and
+// MaxThreads of its Config.
+func TestRuntimeLimits(t *testing.T) {
+	d := godaemonizer.New(godaemonizer.WithName("runtime"))
+	t.Cleanup(func() { d.Kill() })
+	var got []int
+	cfg := &godaemonizer.Config{GOMAXPROCS: 3, MaxThreads: 500}
+	if err := d.DaemonizeWithData(context.Background(), nil, cfg, &got); err != nil {
+		t.Fatalf("Daemonize: %v", err)
+	}
+	if want := []int{3, 500}; !slices.Equal(got, want) {
+		t.Errorf("daemon GOMAXPROCS and max threads %v, want %v", got, want)
+	}
+}
+
+// TestExitBeforeParams checks that a daemon that dies before it reads its
+// params, which are too large for the pipe to hold, fails Daemonize with
+// ErrExitedEarly and how it exited rather than a broken pipe.
+func TestExitBeforeParams(t *testing.T) {
+	params := map[string]string{"blob": strings.Repeat("x", 1<<20)}
+	d := godaemonizer.New(godaemonizer.WithName("exit-now"))
+	err := d.Daemonize(context.Background(), params, nil)
+	if !errors.Is(err, godaemonizer.ErrExitedEarly) || !strings.Contains(err.Error(), "exit status 2") {
+		t.Fatalf("Daemonize: %v, want %v with the exit status", err, godaemonizer.ErrExitedEarly)
+	}
+}
+
+// BenchmarkDaemonizeSmallParams launches a daemon that reports ready at
+// once, with two small params, both with a context that cannot be
+// cancelled, for which the report is read inline, and with one that can.
+func BenchmarkDaemonizeSmallParams(b *testing.B) {
+	params := map[string]any{"name": "worker", "port": 8080}
+	cancellable, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, bc := range []struct {
+		name string
+		ctx  context.Context
+	}{
+		{"background", context.Background()},
+		{"cancellable", cancellable},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				d := godaemonizer.New(godaemonizer.WithName("exit"))
+				if err := d.Daemonize(bc.ctx, params, nil); err != nil {
+					b.Fatal(err)
+				}
+				<-d.Done()
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package daemonizer
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// StartupDeadline returns the time by which the daemon must be ready, from
+// Config.StartupDeadline, or the zero time if there is none. Daemon code can
+// bound its initialization with it, e.g. with context.WithDeadline. Called
+// by the daemon after WaitForParent.
+func (d *Daemon) StartupDeadline() time.Time {
+	return d.deadline
+}
+
+// enforceDeadline reports ErrStartupDeadline through ready and exits the
+// daemon if it has not reported by d.deadline.
+func (d *Daemon) enforceDeadline(ready func(error)) {
+	time.AfterFunc(time.Until(d.deadline), func() {
+		if d.reported.Load() {
+			return
+		}
+		d.logger.Error("daemon not ready in time", "deadline", d.deadline)
+		ready(fmt.Errorf("%w %s", ErrStartupDeadline, d.deadline.Format(time.RFC3339)))
+		// a concurrent successful ready wins, and the daemon runs on
+		if !d.isReady.Load() {
+			os.Exit(1)
+		}
+	})
+}
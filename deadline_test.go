@@ -0,0 +1,50 @@
+package daemonizer_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+func init() {
+	// writes its pid to the file it is given and never becomes ready
+	roles["never-ready"] = func(d *godaemonizer.Daemon) {
+		var path string
+		if _, err := d.WaitForParent(&path); err != nil {
+			os.Exit(1)
+		}
+		os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())), 0o644)
+		select {}
+	}
+}
+
+// TestStartupDeadline checks that a daemon that is not ready by its
+// StartupDeadline fails Daemonize with ErrStartupDeadline and exits.
+func TestStartupDeadline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pid")
+	d := godaemonizer.New(godaemonizer.WithName("never-ready"))
+	t.Cleanup(func() { d.Kill() })
+	cfg := &godaemonizer.Config{StartupDeadline: 200 * time.Millisecond}
+	err := d.Daemonize(context.Background(), path, cfg)
+	var de *godaemonizer.DaemonError
+	if !errors.As(err, &de) || !strings.Contains(de.Message, godaemonizer.ErrStartupDeadline.Error()) {
+		t.Fatalf("Daemonize: %v, want %v", err, godaemonizer.ErrStartupDeadline)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(string(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitExited(t, pid)
+}
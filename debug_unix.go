@@ -0,0 +1,42 @@
+//go:build unix
+
+package daemonizer
+
+import (
+	"fmt"
+	"io"
+	"syscall"
+)
+
+// dumpFds describes fds 0-5 of the current process, one per line.
+func dumpFds(w io.Writer) {
+	for fd := 0; fd <= 5; fd++ {
+		var st syscall.Stat_t
+		if err := syscall.Fstat(fd, &st); err != nil {
+			fmt.Fprintf(w, "daemonizer: fd %d: %v\n", fd, err)
+			continue
+		}
+		fmt.Fprintf(w, "daemonizer: fd %d: %s (dev=%d ino=%d)\n", fd, fileType(uint32(st.Mode)), st.Dev, st.Ino)
+	}
+}
+
+func fileType(mode uint32) string {
+	switch mode & syscall.S_IFMT {
+	case syscall.S_IFIFO:
+		return "pipe"
+	case syscall.S_IFSOCK:
+		return "socket"
+	case syscall.S_IFREG:
+		return "regular file"
+	case syscall.S_IFCHR:
+		return "character device"
+	case syscall.S_IFDIR:
+		return "directory"
+	case syscall.S_IFBLK:
+		return "block device"
+	case syscall.S_IFLNK:
+		return "symlink"
+	default:
+		return "other (e.g. anonymous inode)"
+	}
+}
@@ -0,0 +1,12 @@
+//go:build windows
+
+package daemonizer
+
+import (
+	"fmt"
+	"io"
+)
+
+func dumpFds(w io.Writer) {
+	fmt.Fprintln(w, "daemonizer: fd dump is not supported on Windows")
+}
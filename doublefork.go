@@ -0,0 +1,80 @@
+package daemonizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// forkAgain is all the intermediate process of Config.DoubleFork does: it
+// starts the real daemon with the same command line and handshake files and
+// exits, so that the daemon is orphaned, reparented to init, and, not being
+// a session leader, can never acquire a controlling terminal. The daemon
+// reports to the parent itself; a failure to start it is reported here.
+func (d *Daemon) forkAgain(dec *json.Decoder, paramR, statusW *os.File, h handoff) {
+	if h.Types != nil {
+		d.types = *h.Types
+	}
+	if err := spawnDaemon(dec, paramR, statusW, h); err != nil {
+		d.failParams(paramR, fmt.Errorf("double fork: %w", err))
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// spawnDaemon starts the daemon for forkAgain. The daemon gets the handoff
+// on a fresh pipe at fd 3, the status pipe and every inherited file at the
+// same fds as here, and the parent's param pipe, which carries the cancel
+// and control requests, after them, at h.Relay.
+func spawnDaemon(dec *json.Decoder, paramR, statusW *os.File, h handoff) error {
+	// the only request the parent sends before ready is a cancel
+	if rest, _ := io.ReadAll(dec.Buffered()); len(bytes.TrimSpace(rest)) > 0 {
+		return errors.New("startup cancelled")
+	}
+
+	inherited := h.Files + h.Listeners
+	if h.Tail != nil {
+		inherited += 2
+	}
+	if h.BlobFd != 0 {
+		inherited++
+	}
+	if h.StreamFd != 0 {
+		inherited++
+	}
+	h.DoubleFork = false
+	h.Relay = firstExtraFd + inherited
+	msg, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return pipeError("param pipe", err)
+	}
+	defer w.Close()
+
+	args := launchArgs
+	cmd := exec.Command(executable(args[0]), args[1:]...)
+	cmd.Args[0] = args[0]
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = []*os.File{r, statusW}
+	for i := range inherited {
+		cmd.ExtraFiles = append(cmd.ExtraFiles, os.NewFile(uintptr(firstExtraFd+i), fmt.Sprintf("inherited_%d", i)))
+	}
+	cmd.ExtraFiles = append(cmd.ExtraFiles, paramR)
+	err = cmd.Start()
+	r.Close()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("send params: %w", err)
+	}
+	return nil
+}
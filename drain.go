@@ -0,0 +1,120 @@
+package daemonizer
+
+import (
+	"context"
+	"net"
+	"sync"
+)
+
+// DrainHelper wraps a net.Listener and tracks the connections it accepts,
+// so a network daemon can shut down gracefully: Drain stops accepting and
+// waits for in-flight connections to finish. It is typically registered
+// with OnShutdown:
+//
+//	ln := godaemonizer.NewDrainHelper(listener)
+//	d.OnShutdown(ln.Drain)
+type DrainHelper struct {
+	net.Listener
+
+	mu    sync.Mutex
+	conns map[*drainConn]struct{}
+	idle  chan struct{} // closed when draining and no connections are left
+	drain bool
+}
+
+// NewDrainHelper wraps l for connection tracking.
+func NewDrainHelper(l net.Listener) *DrainHelper {
+	return &DrainHelper{
+		Listener: l,
+		conns:    make(map[*drainConn]struct{}),
+		idle:     make(chan struct{}),
+	}
+}
+
+// Accept waits for the next connection and tracks it until it is closed.
+func (h *DrainHelper) Accept() (net.Conn, error) {
+	conn, err := h.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.drain {
+		conn.Close()
+		return nil, net.ErrClosed
+	}
+	c := &drainConn{Conn: conn, h: h}
+	h.conns[c] = struct{}{}
+	return c, nil
+}
+
+// Drain closes the listener and waits for the tracked connections to be
+// closed. If ctx expires first, the remaining connections are closed
+// forcibly and ctx's error is returned.
+func (h *DrainHelper) Drain(ctx context.Context) error {
+	h.mu.Lock()
+	if !h.drain {
+		h.drain = true
+		h.Listener.Close()
+		h.checkIdle()
+	}
+	h.mu.Unlock()
+
+	select {
+	case <-h.idle:
+		return nil
+	case <-ctx.Done():
+	}
+
+	h.mu.Lock()
+	conns := make([]*drainConn, 0, len(h.conns))
+	for c := range h.conns {
+		conns = append(conns, c)
+	}
+	h.mu.Unlock()
+
+	// through the wrapper, which stops tracking each one
+	for _, c := range conns {
+		c.Close()
+	}
+	return ctx.Err()
+}
+
+// Active returns the number of connections not yet closed.
+func (h *DrainHelper) Active() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.conns)
+}
+
+// checkIdle closes idle once draining has left no connections. h.mu must
+// be held.
+func (h *DrainHelper) checkIdle() {
+	if h.drain && len(h.conns) == 0 {
+		select {
+		case <-h.idle:
+		default:
+			close(h.idle)
+		}
+	}
+}
+
+// drainConn removes itself from its DrainHelper when closed.
+type drainConn struct {
+	net.Conn
+	h    *DrainHelper
+	once sync.Once
+}
+
+func (c *drainConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() {
+		c.h.mu.Lock()
+		delete(c.h.conns, c)
+		c.h.checkIdle()
+		c.h.mu.Unlock()
+	})
+	return err
+}
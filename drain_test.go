@@ -0,0 +1,43 @@
+package daemonizer_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+// TestDrainTimeout checks that Drain closes the connections still open at
+// its deadline and stops counting them.
+func TestDrainTimeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := godaemonizer.NewDrainHelper(l)
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := h.Accept(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := h.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Drain: %v, want %v", err, context.DeadlineExceeded)
+	}
+	if n := h.Active(); n != 0 {
+		t.Errorf("Active() = %d after Drain closed every connection", n)
+	}
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := client.Read(make([]byte, 1)); err == nil {
+		t.Error("the connection is still open")
+	}
+}
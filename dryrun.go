@@ -0,0 +1,60 @@
+package daemonizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// PlannedExec describes the daemon process that Daemonize would start.
+type PlannedExec struct {
+	Path   string   // resolved binary path
+	Args   []string // full argv, including the daemon marker
+	Dir    string   // working directory (empty = inherit)
+	Env    []string // environment (nil = inherit)
+	Files  []string // child fd layout, indexed by fd number
+	Params []byte   // serialized params, with Config.SensitiveKeys redacted
+}
+
+// DryRun reports the invocation Daemonize would use for params and cfg
+// without starting anything. Config.Secrets are never included.
+// Called by the parent process.
+func (d *Daemon) DryRun(params any, cfg *Config) (*PlannedExec, error) {
+	if d.isDaemon || daemonProcess.Load() {
+		return nil, ErrAlreadyDaemon
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	cmd := d.command(cfg)
+	if cmd.Err != nil {
+		return nil, fmt.Errorf("resolve daemon binary: %w", cmd.Err)
+	}
+	attachPipes(cmd, nil, nil, cfg, nil)
+
+	params = d.sanitizeParams(params, cfg)
+	payload, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("encode params: %w", err)
+	}
+
+	layout := d.layoutFds(cfg, [3]string{fileName(cmd.Stdin), fileName(cmd.Stdout), fileName(cmd.Stderr)})
+
+	return &PlannedExec{
+		Path:   cmd.Path,
+		Args:   cmd.Args,
+		Dir:    cmd.Dir,
+		Env:    cmd.Env,
+		Files:  layout.names,
+		Params: redactParams(payload, cfg),
+	}, nil
+}
+
+// fileName names a stdio stream as the child will see it.
+func fileName(stream any) string {
+	if f, ok := stream.(*os.File); ok && f != nil {
+		return f.Name()
+	}
+	return os.DevNull
+}
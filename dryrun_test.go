@@ -0,0 +1,66 @@
+package daemonizer_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+// TestDryRunFiles checks that DryRun reports every fd launch passes, in
+// the order it passes them.
+func TestDryRunFiles(t *testing.T) {
+	extra, err := os.Create(filepath.Join(t.TempDir(), "extra"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer extra.Close()
+
+	d := godaemonizer.New()
+	d.SetBinaryParam("blob", []byte("data"))
+	plan, err := d.DryRun(nil, &godaemonizer.Config{
+		ExtraFiles:     []*os.File{extra},
+		TailOutput:     io.Discard,
+		TailDuration:   time.Second,
+		ParamStream:    strings.NewReader("stream"),
+		ReadinessViaFd: true,
+		Transport:      godaemonizer.TransportSocketpair,
+	})
+	if err != nil {
+		t.Fatalf("DryRun: %v", err)
+	}
+	want := []string{
+		os.DevNull, "tail_pipe", "tail_pipe",
+		"param_socket", "status_socket",
+		extra.Name(),
+		"tail:" + os.DevNull, "tail:" + os.DevNull,
+		"binary_params", "param_stream", "ready_pipe",
+	}
+	if !slices.Equal(plan.Files, want) {
+		t.Errorf("Files = %q, want %q", plan.Files, want)
+	}
+}
+
+// TestDryRunWrapper checks that DryRun reports the wrapper's invocation.
+func TestDryRunWrapper(t *testing.T) {
+	d := godaemonizer.New()
+	plan, err := d.DryRun(nil, &godaemonizer.Config{WrapperCommand: []string{"/usr/bin/nice", "-n", "5"}})
+	if err != nil {
+		t.Fatalf("DryRun: %v", err)
+	}
+	if plan.Path != "/usr/bin/nice" {
+		t.Errorf("Path = %q, want the wrapper", plan.Path)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Args) < 4 || !slices.Equal(plan.Args[:4], []string{"/usr/bin/nice", "-n", "5", exe}) {
+		t.Errorf("Args = %q, want the wrapper running %s", plan.Args, exe)
+	}
+}
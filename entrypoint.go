@@ -0,0 +1,51 @@
+package daemonizer
+
+import (
+	"errors"
+	"os"
+)
+
+// entrypoint is the daemon's main registered with SetDaemonEntrypoint.
+var entrypoint func(params map[string]any)
+
+var errNoEntrypoint = errors.New("no daemon entrypoint registered")
+
+// SetDaemonEntrypoint registers fn as the daemon's main for
+// RunDaemonEntrypoint, for programs that do not branch on IsDaemon in main
+// themselves, such as a test binary that daemonizes itself. fn gets the
+// params decoded like ParamsMap, once the parent has been told the daemon
+// is ready, and the daemon exits when fn returns. Register it before
+// RunDaemonEntrypoint, e.g. in an init function.
+func SetDaemonEntrypoint(fn func(params map[string]any)) {
+	entrypoint = fn
+}
+
+// RunDaemonEntrypoint returns right away in the parent. In the daemon it
+// waits for the params, reports readiness, runs the entrypoint registered
+// with SetDaemonEntrypoint, and exits; a daemon without an entrypoint
+// reports the failure to the parent instead. opts are passed to New.
+//
+// It makes end-to-end tests of daemonization use the standard test binary:
+// call it first thing in TestMain, and the binary, re-executed by
+// Daemonize in a test, runs the entrypoint rather than the tests.
+func RunDaemonEntrypoint(opts ...Option) {
+	d := New(opts...)
+	if !d.IsDaemon() {
+		return
+	}
+
+	ready, err := d.WaitForParent(nil)
+	if err != nil {
+		os.Exit(1)
+	}
+	params, err := d.ParamsMap()
+	if err == nil && entrypoint == nil {
+		err = errNoEntrypoint
+	}
+	ready(err)
+	if err != nil {
+		os.Exit(1)
+	}
+	entrypoint(params)
+	os.Exit(0)
+}
@@ -0,0 +1,81 @@
+package daemonizer
+
+import (
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// InheritEnvWith sets Env to the parent's environment merged with extra.
+// Values in extra override inherited values with the same key.
+func (c *Config) InheritEnvWith(extra map[string]string) {
+	c.Env = mergeEnv(os.Environ(), extra)
+}
+
+// SetEnv sets Env to exactly the variables in env, replacing anything
+// inherited from the parent.
+func (c *Config) SetEnv(env map[string]string) {
+	c.Env = mergeEnv(nil, env)
+}
+
+// InheritEnvOnly sets Env to the parent's values of the named variables
+// only, dropping everything else. Variables the parent does not have are
+// left unset.
+func (c *Config) InheritEnvOnly(keys ...string) {
+	env := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if value, ok := os.LookupEnv(key); ok {
+			env = append(env, key+"="+value)
+		}
+	}
+	c.Env = mergeEnv(env, nil)
+}
+
+// LaunchEnv looks up key in the environment the parent intended the daemon
+// to have: Config.Env, or the parent's own environment if that was nil. It
+// is unaffected by anything that changed the daemon's actual environment
+// on the way, such as a wrapper or the OS. As with os.LookupEnv, the last
+// value of a duplicated key wins. With Config.ParamFile the parent keeps the
+// environment out of the file, and LaunchEnv looks in the daemon's own.
+// Called by the daemon process after WaitForParent.
+func (d *Daemon) LaunchEnv(key string) (string, bool) {
+	env := d.launchEnv
+	if env == nil {
+		env = os.Environ()
+	}
+	var value string
+	found := false
+	for _, kv := range env {
+		if k, v, ok := strings.Cut(kv, "="); ok && k == key {
+			value, found = v, true
+		}
+	}
+	return value, found
+}
+
+// mergeEnv applies extra on top of base, deduplicating keys so that the last
+// value for a key wins. Keys keep the position of their first occurrence;
+// new keys from extra are appended in sorted order for reproducibility.
+func mergeEnv(base []string, extra map[string]string) []string {
+	env := make([]string, 0, len(base)+len(extra))
+	index := make(map[string]int, len(base)+len(extra))
+
+	set := func(key, kv string) {
+		if i, ok := index[key]; ok {
+			env[i] = kv
+			return
+		}
+		index[key] = len(env)
+		env = append(env, kv)
+	}
+
+	for _, kv := range base {
+		key, _, _ := strings.Cut(kv, "=")
+		set(key, kv)
+	}
+	for _, key := range slices.Sorted(maps.Keys(extra)) {
+		set(key, key+"="+extra[key])
+	}
+	return env
+}
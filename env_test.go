@@ -0,0 +1,85 @@
+package daemonizer_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+const envProbe = "DAEMONIZER_TEST_PROBE"
+
+func init() {
+	roles["launch-env"] = func(d *godaemonizer.Daemon) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		value, _ := d.LaunchEnv(envProbe)
+		d.SetReadyData(value)
+		ready(nil)
+	}
+	roles["environ"] = func(d *godaemonizer.Daemon) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		d.SetReadyData(os.Environ())
+		ready(nil)
+	}
+}
+
+// TestParamFileLeavesEnvOut checks that the environment is not written to
+// a param file, and that LaunchEnv still finds it.
+func TestParamFileLeavesEnvOut(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "params")
+	cfg := &godaemonizer.Config{ParamFile: path, KeepParamFile: true}
+	cfg.InheritEnvWith(map[string]string{envProbe: "probe-value"})
+
+	d := godaemonizer.New(godaemonizer.WithName("launch-env"))
+	t.Cleanup(func() { d.Kill() })
+	var value string
+	if err := d.DaemonizeWithData(context.Background(), map[string]any{}, cfg, &value); err != nil {
+		t.Fatalf("Daemonize: %v", err)
+	}
+	if value != "probe-value" {
+		t.Errorf("LaunchEnv(%s) = %q, want %q", envProbe, value, "probe-value")
+	}
+
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(written, []byte(envProbe)) {
+		t.Errorf("param file holds the environment: %s", written)
+	}
+}
+
+// TestInheritEnvOnly checks that the daemon gets the listed variables of
+// the parent and none of the others.
+func TestInheritEnvOnly(t *testing.T) {
+	t.Setenv("DAEMONIZER_TEST_KEEP", "kept")
+	t.Setenv("DAEMONIZER_TEST_DROP", "dropped")
+	cfg := &godaemonizer.Config{}
+	cfg.InheritEnvOnly("DAEMONIZER_TEST_KEEP", "DAEMONIZER_TEST_UNSET")
+
+	d := godaemonizer.New(godaemonizer.WithName("environ"))
+	t.Cleanup(func() { d.Kill() })
+	var env []string
+	if err := d.DaemonizeWithData(context.Background(), nil, cfg, &env); err != nil {
+		t.Fatalf("Daemonize: %v", err)
+	}
+	if !slices.Contains(env, "DAEMONIZER_TEST_KEEP=kept") {
+		t.Errorf("daemon environment %q lacks DAEMONIZER_TEST_KEEP", env)
+	}
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "DAEMONIZER_TEST_DROP=") || strings.HasPrefix(kv, "DAEMONIZER_TEST_UNSET=") || strings.HasPrefix(kv, "HOME=") {
+			t.Errorf("daemon inherited %s", kv)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package daemonizer
+
+import (
+	"time"
+)
+
+// LifecycleEventKind identifies a step of Daemonize.
+type LifecycleEventKind int
+
+const (
+	// EventSpawned: the daemon process was started.
+	EventSpawned LifecycleEventKind = iota
+	// EventParamsSent: the daemon was handed its params.
+	EventParamsSent
+	// EventProgress: the daemon reported progress; see Message.
+	EventProgress
+	// EventReady: the daemon reported readiness; Daemonize succeeded.
+	EventReady
+	// EventError: Daemonize failed; see Err.
+	EventError
+)
+
+func (k LifecycleEventKind) String() string {
+	switch k {
+	case EventSpawned:
+		return "spawned"
+	case EventParamsSent:
+		return "params_sent"
+	case EventProgress:
+		return "progress"
+	case EventReady:
+		return "ready"
+	case EventError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// LifecycleEvent is one step of Daemonize, as delivered by Events.
+type LifecycleEvent struct {
+	Kind    LifecycleEventKind
+	Time    time.Time
+	PID     int    // with EventSpawned and EventReady
+	Message string // with EventProgress
+	Err     error  // with EventError
+}
+
+// eventBuffer is how many events Events holds for a slow reader; further
+// ones are dropped rather than stall the handshake.
+const eventBuffer = 64
+
+// Events returns a channel of the steps every Daemonize on d goes through,
+// with timestamps, e.g. to show startup progress. Each Daemonize ends with
+// EventReady or EventError. The channel is buffered and never closed;
+// events that do not fit are dropped, so a slow reader cannot block
+// Daemonize. Call it before Daemonize. Called by the parent process.
+func (d *Daemon) Events() <-chan LifecycleEvent {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.events == nil {
+		d.events = make(chan LifecycleEvent, eventBuffer)
+	}
+	return d.events
+}
+
+// emit delivers ev to Events, if anyone asked for them.
+func (d *Daemon) emit(ev LifecycleEvent) {
+	d.mu.Lock()
+	events := d.events
+	d.mu.Unlock()
+
+	if events == nil {
+		return
+	}
+	ev.Time = time.Now()
+	select {
+	case events <- ev:
+	default:
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -12,7 +13,12 @@ import (
 
 type EchoServerConfig struct {
 	Host string `json:"host"`
-	Port int    `json:"port"`
+	Port int    `json:"port"` // 0 lets the OS choose
+}
+
+// EchoServerInfo is what the daemon reports back once it is listening.
+type EchoServerInfo struct {
+	Addr string `json:"addr"`
 }
 
 func main() {
@@ -21,16 +27,17 @@ func main() {
 	if !d.IsDaemon() {
 		cfg := &EchoServerConfig{
 			Host: "localhost",
-			Port: 8080,
+			Port: 0,
 		}
 
-		err := d.Daemonize(context.Background(), cfg, nil)
+		var info EchoServerInfo
+		err := d.DaemonizeWithData(context.Background(), cfg, nil, &info)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "failed to daemonize: %v\n", err)
 			os.Exit(1)
 		}
 
-		fmt.Println("daemon started successfully")
+		fmt.Printf("daemon started successfully, echo server listening on %s\n", info.Addr)
 		return
 	}
 
@@ -43,21 +50,31 @@ func main() {
 	}
 
 	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
-	listener, err := net.Listen("tcp", addr)
+	ln, err := net.Listen("tcp", addr)
 	if err != nil {
 		ready(err)
 		os.Exit(1)
 	}
 
-	// signal parent that we're ready
+	// on SIGTERM, stop accepting and let open connections finish copying
+	listener := godaemonizer.NewDrainHelper(ln)
+	d.OnShutdown(listener.Drain)
+
+	// signal parent that we're ready, telling it the port the OS chose
+	d.SetReadyData(EchoServerInfo{Addr: ln.Addr().String()})
 	ready(nil)
 
-	fmt.Printf("echo server listening on %s\n", addr)
 	for {
 		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			select {} // draining; OnShutdown exits when done
+		}
 		if err != nil {
 			continue
 		}
-		go io.Copy(conn, conn)
+		go func() {
+			defer conn.Close()
+			io.Copy(conn, conn)
+		}()
 	}
 }
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+// TestMain runs the example's daemon when the test binary is started as
+// one.
+func TestMain(m *testing.M) {
+	if godaemonizer.New().IsDaemon() {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// TestEchoServerChosenPort starts the echo server on a port the OS
+// chooses and connects to the address it reports.
+func TestEchoServerChosenPort(t *testing.T) {
+	d := godaemonizer.New()
+	var info EchoServerInfo
+	cfg := EchoServerConfig{Host: "127.0.0.1", Port: 0}
+	if err := d.DaemonizeWithData(context.Background(), cfg, nil, &info); err != nil {
+		t.Fatalf("Daemonize: %v", err)
+	}
+	t.Cleanup(func() { d.Stop(5 * time.Second) })
+
+	conn, err := net.DialTimeout("tcp", info.Addr, 5*time.Second)
+	if err != nil {
+		t.Fatalf("dial the reported address %q: %v", info.Addr, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	echo := make([]byte, 5)
+	if _, err := io.ReadFull(conn, echo); err != nil {
+		t.Fatal(err)
+	}
+	if string(echo) != "hello" {
+		t.Errorf("echoed %q, want %q", echo, "hello")
+	}
+}
@@ -0,0 +1,45 @@
+package daemonizer_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+// TestMain makes the test binary its own daemon: in the copy that a test's
+// Daemonize starts, RunDaemonEntrypoint runs the entrypoint and exits, so
+// no tests run there. The package's own tests start named daemons, which
+// runRole runs instead.
+func TestMain(m *testing.M) {
+	godaemonizer.SetDaemonEntrypoint(func(params map[string]any) {
+		os.WriteFile(params["out"].(string), []byte("daemon ran"), 0o644)
+	})
+	runRole()
+	godaemonizer.RunDaemonEntrypoint()
+	os.Exit(m.Run())
+}
+
+func ExampleRunDaemonEntrypoint() {
+	dir, err := os.MkdirTemp("", "daemonizer-example")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out")
+
+	d := godaemonizer.New()
+	if err := d.Daemonize(context.Background(), map[string]any{"out": out}, nil); err != nil {
+		fmt.Println(err)
+		return
+	}
+	<-d.Done()
+
+	b, _ := os.ReadFile(out)
+	fmt.Println(string(b))
+	// Output: daemon ran
+}
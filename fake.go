@@ -0,0 +1,146 @@
+package daemonizer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+)
+
+// Daemonizer is the API shared by Daemon and Fake. Depend on it instead of
+// *Daemon to be able to inject a Fake in tests.
+type Daemonizer interface {
+	IsDaemon() bool
+	Args() []string
+	Daemonize(ctx context.Context, params any, cfg *Config) error
+	DaemonizeWithData(ctx context.Context, params any, cfg *Config, data any) error
+	WaitForParent(dest any) (ready func(error), err error)
+	SetReadyData(data any)
+}
+
+var (
+	_ Daemonizer = (*Daemon)(nil)
+	_ Daemonizer = (*Fake)(nil)
+)
+
+// Fake is an in-process Daemonizer for testing code that daemonizes. Its
+// Daemonize runs the daemon function in a goroutine, handing it a daemon-side
+// Fake, instead of spawning a process. Params and ready data go through the
+// same JSON encoding as with a real daemon.
+type Fake struct {
+	daemon   func(d Daemonizer)
+	isDaemon bool
+
+	mu        sync.Mutex
+	params    []byte
+	readyData any
+	reported  sync.Once
+	statusCh  chan status
+}
+
+// NewFake returns a parent-side Fake whose Daemonize runs daemon in-process.
+// daemon receives a Fake for which IsDaemon returns true and should follow
+// the same steps as a real daemon: WaitForParent, then ready.
+func NewFake(daemon func(d Daemonizer)) *Fake {
+	return &Fake{daemon: daemon}
+}
+
+func (f *Fake) IsDaemon() bool {
+	return f.isDaemon
+}
+
+func (f *Fake) Args() []string {
+	return slices.Clone(os.Args)
+}
+
+func (f *Fake) Daemonize(ctx context.Context, params any, cfg *Config) error {
+	return f.DaemonizeWithData(ctx, params, cfg, nil)
+}
+
+// DaemonizeWithData records params, runs the daemon function and waits for
+// it to report readiness. cfg is accepted for compatibility and ignored.
+func (f *Fake) DaemonizeWithData(ctx context.Context, params any, cfg *Config, data any) error {
+	if f.isDaemon {
+		return ErrAlreadyDaemon
+	}
+
+	payload, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("encode params: %w", err)
+	}
+
+	f.mu.Lock()
+	f.params = payload
+	f.mu.Unlock()
+
+	child := &Fake{isDaemon: true, params: payload, statusCh: make(chan status, 1)}
+	go func() {
+		f.daemon(child)
+		child.report(status{Error: "daemon returned without reporting readiness"})
+	}()
+
+	select {
+	case st := <-child.statusCh:
+		if !st.OK {
+			return &DaemonError{Code: st.Code, Message: st.Error}
+		}
+		if data != nil && len(st.Data) > 0 {
+			if err := json.Unmarshal(st.Data, data); err != nil {
+				return fmt.Errorf("decode ready data: %w", err)
+			}
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Params deserializes the params of the last Daemonize call into dest, so
+// tests can assert on what would have been sent to the daemon.
+func (f *Fake) Params(dest any) error {
+	f.mu.Lock()
+	payload := f.params
+	f.mu.Unlock()
+
+	if payload == nil {
+		return ErrNotStarted
+	}
+	return json.Unmarshal(payload, dest)
+}
+
+func (f *Fake) WaitForParent(dest any) (ready func(error), err error) {
+	if !f.isDaemon {
+		return nil, errors.New("not a daemon process")
+	}
+	if dest != nil {
+		if err := json.Unmarshal(f.params, dest); err != nil {
+			return nil, fmt.Errorf("read params: %w", err)
+		}
+	}
+
+	ready = func(initErr error) {
+		f.mu.Lock()
+		readyData := f.readyData
+		f.mu.Unlock()
+
+		f.report(newStatus(initErr, readyData))
+	}
+	return ready, nil
+}
+
+func (f *Fake) SetReadyData(data any) {
+	f.mu.Lock()
+	f.readyData = data
+	f.mu.Unlock()
+}
+
+// report delivers the first status only, like the real status pipe which is
+// closed after ready.
+func (f *Fake) report(st status) {
+	f.reported.Do(func() {
+		f.statusCh <- st
+	})
+}
@@ -0,0 +1,24 @@
+package daemonizer_test
+
+import (
+	"context"
+	"testing"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+// TestFakeIgnoredParams checks that a Fake daemon, like a real one, may
+// pass nil to WaitForParent to ignore its params.
+func TestFakeIgnoredParams(t *testing.T) {
+	f := godaemonizer.NewFake(func(d godaemonizer.Daemonizer) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			t.Errorf("WaitForParent(nil): %v", err)
+			return
+		}
+		ready(nil)
+	})
+	if err := f.Daemonize(context.Background(), map[string]any{"port": 8080}, nil); err != nil {
+		t.Fatalf("Daemonize: %v", err)
+	}
+}
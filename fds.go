@@ -0,0 +1,61 @@
+package daemonizer
+
+import "os"
+
+// invalidFd is what the fd accessors return for a pipe that is not open,
+// matching (*os.File).Fd on a closed file.
+const invalidFd = ^uintptr(0)
+
+// ParamPipeFd returns the fd number of the param pipe, which carries params
+// and control requests from the parent. In the daemon that is the read end,
+// from WaitForParent until the parent closes the pipe; it is normally 3. In
+// the parent it is the write end, open after Daemonize only with
+// Config.Control. Otherwise it returns ^uintptr(0).
+func (d *Daemon) ParamPipeFd() uintptr {
+	if d.isDaemon {
+		return fdOf(d.paramR)
+	}
+	if d.channel == nil {
+		return invalidFd
+	}
+	w, _ := d.channel.w.(*os.File)
+	return fdOf(w)
+}
+
+// OutputPipeFd returns the fd number of the status pipe, which carries the
+// startup report, replies and events from the daemon. In the daemon that is
+// the write end, normally 4, until it is closed. In the parent it is the
+// read end, open after Daemonize only with Config.Control or
+// Config.KeepOutputOpen. Otherwise it returns ^uintptr(0).
+func (d *Daemon) OutputPipeFd() uintptr {
+	if d.isDaemon {
+		d.outMu.Lock()
+		defer d.outMu.Unlock()
+		if d.out == nil {
+			return invalidFd
+		}
+		return fdOf(d.statusW)
+	}
+	if d.channel == nil {
+		return invalidFd
+	}
+	r, _ := d.channel.r.(*os.File)
+	return fdOf(r)
+}
+
+// fdOf returns f's fd number. Unlike (*os.File).Fd it leaves the file in
+// non-blocking mode, so deadlines and the runtime poller keep working.
+func fdOf(f *os.File) uintptr {
+	if f == nil {
+		return invalidFd
+	}
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return invalidFd
+	}
+	fd := invalidFd
+	if rc.Control(func(n uintptr) { fd = n }) != nil {
+		return invalidFd
+	}
+	return fd
+}
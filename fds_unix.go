@@ -0,0 +1,86 @@
+//go:build unix
+
+package daemonizer
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// accessMode reports whether f is open for reading and for writing, per
+// fcntl(F_GETFL). Both are reported true if that cannot be told, leaving
+// the failure to the actual read or write.
+func accessMode(f *os.File) (readable, writable bool) {
+	flags, err := unix.FcntlInt(fdOf(f), unix.F_GETFL, 0)
+	if err != nil {
+		return true, true
+	}
+	switch flags & unix.O_ACCMODE {
+	case unix.O_RDONLY:
+		return true, false
+	case unix.O_WRONLY:
+		return false, true
+	}
+	return true, true
+}
+
+// anonymous reports whether fi is an inode without a file type, such as
+// an epoll or eventfd fd, which os.FileMode reports as a regular file. The
+// Go runtime opens those at startup, on the lowest free fds, so they take
+// fds 3 and 4 in a daemon that did not inherit them.
+func anonymous(fi os.FileInfo) bool {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	return ok && st.Mode&syscall.S_IFMT == 0
+}
+
+// replaceFd puts a duplicate of f on fd, closing what fd held before.
+func replaceFd(f *os.File, fd int) error {
+	return unix.Dup2(int(fdOf(f)), fd)
+}
+
+// detachStdin moves the param pipe the parent passed on fd 0 to a new fd
+// and puts /dev/null in its place, so that nothing mistakes the pipe for
+// interactive input.
+func detachStdin() (*os.File, error) {
+	fd, err := unix.FcntlInt(0, unix.F_DUPFD_CLOEXEC, 3)
+	if err != nil {
+		return nil, fmt.Errorf("move param pipe off stdin: %w", err)
+	}
+	null, err := os.Open(os.DevNull)
+	if err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	defer null.Close()
+	if err := unix.Dup2(int(null.Fd()), 0); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("replace stdin: %w", err)
+	}
+	pollable(fd)
+	return os.NewFile(uintptr(fd), "param_pipe"), nil
+}
+
+// nullStdio puts /dev/null in place of the given standard streams.
+func nullStdio(fds []int) error {
+	null, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer null.Close()
+	for _, fd := range fds {
+		if err := unix.Dup2(int(null.Fd()), fd); err != nil {
+			return fmt.Errorf("replace fd %d: %w", fd, err)
+		}
+	}
+	return nil
+}
+
+// pollable puts fd in non-blocking mode before it is wrapped in an
+// *os.File, so that the runtime poller serves it and read deadlines work.
+// Inherited pipes arrive in blocking mode.
+func pollable(fd int) {
+	unix.SetNonblock(fd, true)
+}
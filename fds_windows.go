@@ -0,0 +1,29 @@
+package daemonizer
+
+import (
+	"errors"
+	"os"
+)
+
+func detachStdin() (*os.File, error) {
+	return nil, errors.New("params on stdin are not supported on Windows")
+}
+
+func nullStdio(fds []int) error {
+	return errors.New("detaching from the terminal is not supported on Windows")
+}
+
+func pollable(fd int) {}
+
+func replaceFd(f *os.File, fd int) error {
+	return errors.New("reopening logs is not supported on Windows")
+}
+
+func anonymous(fi os.FileInfo) bool {
+	return false
+}
+
+// accessMode is not checked on Windows.
+func accessMode(f *os.File) (readable, writable bool) {
+	return true, true
+}
@@ -0,0 +1,34 @@
+package daemonizer
+
+import (
+	"flag"
+	"strings"
+)
+
+// RegisterFlag declares the daemon marker on fs, so that parsing the
+// daemon's command line with the flag package accepts it instead of failing
+// with an unknown flag. This matters for programs that call flag.Parse
+// before New, e.g. in an init function; New reads the marker from the
+// command line itself either way. Pass the same WithMarker option as to New,
+// if any. A marker that does not start with "-" is not a flag and is left
+// alone. Call it before fs.Parse, e.g. RegisterFlag(flag.CommandLine).
+func RegisterFlag(fs *flag.FlagSet, opts ...Option) {
+	d := &Daemon{marker: daemonFlag}
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	name := strings.TrimLeft(d.marker, "-")
+	if name == d.marker || name == "" {
+		return
+	}
+	fs.Var(new(markerFlag), name, "set by the parent when it starts this program as a daemon")
+}
+
+// markerFlag accepts the marker with or without a daemon name, as a boolean
+// flag does with or without a value.
+type markerFlag string
+
+func (f *markerFlag) String() string     { return string(*f) }
+func (f *markerFlag) Set(s string) error { *f = markerFlag(s); return nil }
+func (f *markerFlag) IsBoolFlag() bool   { return true }
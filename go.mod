@@ -3,3 +3,5 @@ module github.com/cyverse/go-daemonizer
 go 1.23.0
 
 toolchain go1.24.2
+
+require golang.org/x/sys v0.30.0
@@ -0,0 +1,39 @@
+package daemonizer
+
+import (
+	"errors"
+	"net"
+	"net/http"
+)
+
+// StartHealthServer serves liveness and readiness probes on addr:
+// /healthz always answers 200, and /readyz answers 200 only once ready(nil)
+// has been called, 503 before. The listener is bound before returning so
+// address errors surface immediately; shut the returned server down with
+// Shutdown or Close. Called by the daemon process.
+func (d *Daemon) StartHealthServer(addr string) (*http.Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !d.isReady.Load() {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ready\n"))
+	})
+
+	srv := &http.Server{Addr: ln.Addr().String(), Handler: mux}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			d.logger.Error("health server stopped", "error", err)
+		}
+	}()
+	return srv, nil
+}
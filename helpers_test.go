@@ -0,0 +1,135 @@
+package daemonizer_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+// roles are the daemons the tests start from the test binary, by name. A
+// role calls WaitForParent itself, so that it controls the handshake.
+var roles = map[string]func(d *godaemonizer.Daemon){}
+
+// keepArgs names the roles that run with PreserveOSArgs.
+var keepArgs = map[string]bool{}
+
+// testMarker is the marker of the daemons started with WithMarker.
+const testMarker = "--test-daemon"
+
+// parents are the ordinary processes, as opposed to daemons, that the tests
+// start from the test binary with startParent, by name, e.g. a parent that
+// daemonizes a role itself.
+var parents = map[string]func(){}
+
+// parentEnv names the parent the test binary was started as.
+const parentEnv = "DAEMONIZER_TEST_PARENT"
+
+// runRole runs the role or parent the test binary was started as, with
+// either marker, if any, and exits. Call it first thing in TestMain.
+func runRole() {
+	for _, marker := range []string{"", testMarker} {
+		d := godaemonizer.New(godaemonizer.WithMarker(marker), godaemonizer.PreserveOSArgs())
+		if !d.IsDaemon() || d.Name() == "" {
+			continue
+		}
+		role := roles[d.Name()]
+		if role == nil {
+			os.Exit(3)
+		}
+		if !keepArgs[d.Name()] {
+			d = godaemonizer.New(godaemonizer.WithMarker(marker))
+		}
+		role(d)
+		os.Exit(0)
+	}
+	// the daemons a parent starts inherit parentEnv, but have a marker
+	if name := os.Getenv(parentEnv); name != "" {
+		parent := parents[name]
+		if parent == nil {
+			os.Exit(3)
+		}
+		parent()
+		os.Exit(0)
+	}
+}
+
+// serve is the body of a role that just runs: it reports ready and waits
+// to be stopped.
+func serve(d *godaemonizer.Daemon) {
+	ready, err := d.WaitForParent(nil)
+	if err != nil {
+		os.Exit(1)
+	}
+	ready(nil)
+	select {}
+}
+
+func init() {
+	roles["serve"] = serve
+	roles["exit"] = func(d *godaemonizer.Daemon) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		ready(nil)
+	}
+}
+
+// startDaemon daemonizes role and kills the daemon when the test ends.
+func startDaemon(t *testing.T, role string, params any, cfg *godaemonizer.Config) *godaemonizer.Daemon {
+	t.Helper()
+	d := godaemonizer.New(godaemonizer.WithName(role))
+	if err := d.Daemonize(context.Background(), params, cfg); err != nil {
+		t.Fatalf("Daemonize %s: %v", role, err)
+	}
+	t.Cleanup(func() { d.Kill() })
+	return d
+}
+
+// waitExited waits up to a few seconds for the process pid to exit, which
+// a zombie that is still to be reaped has. It needs /proc.
+func waitExited(t *testing.T, pid int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		if errors.Is(err, os.ErrNotExist) {
+			return
+		} else if err != nil {
+			t.Skipf("cannot watch process %d: %v", pid, err)
+		}
+		if i := bytes.LastIndexByte(stat, ')'); i >= 0 && i+2 < len(stat) && stat[i+2] == 'Z' {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("process %d still running", pid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// startParent starts the test binary as the parent name, with its stdout
+// going to stdout, and kills it when the test ends.
+func startParent(t *testing.T, name string, stdout io.Writer) *exec.Cmd {
+	t.Helper()
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), parentEnv+"="+name)
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start parent %s: %v", name, err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	return cmd
+}
@@ -0,0 +1,47 @@
+package daemonizer
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// OnReloadSignal registers fn to run each time the daemon receives SIGHUP,
+// the traditional cue for a daemon to reread its configuration; errors are
+// logged. Signals arriving while fn runs are coalesced into one more call.
+// Registering again replaces fn, and a nil fn unregisters it, which gives
+// SIGHUP its default effect again. It needs no control channel, unlike
+// OnReload. Called by the daemon process.
+func (d *Daemon) OnReloadSignal(fn func() error) {
+	d.hupMu.Lock()
+	defer d.hupMu.Unlock()
+
+	d.hupFn = fn
+	switch {
+	case fn == nil && d.hupSig != nil:
+		signal.Stop(d.hupSig)
+		close(d.hupSig)
+		d.hupSig = nil
+	case fn != nil && d.hupSig == nil:
+		d.hupSig = make(chan os.Signal, 1)
+		signal.Notify(d.hupSig, syscall.SIGHUP)
+		go d.awaitReloadSignals(d.hupSig)
+	}
+}
+
+// awaitReloadSignals runs the SIGHUP callback for every signal.
+func (d *Daemon) awaitReloadSignals(sig <-chan os.Signal) {
+	for range sig {
+		d.hupMu.Lock()
+		fn := d.hupFn
+		d.hupMu.Unlock()
+		if fn == nil {
+			continue // unregistered after the signal arrived
+		}
+
+		d.logger.Info("reloading", "signal", syscall.SIGHUP.String())
+		if err := fn(); err != nil {
+			d.logger.Error("reload failed", "error", err)
+		}
+	}
+}
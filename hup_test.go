@@ -0,0 +1,43 @@
+//go:build unix
+
+package daemonizer_test
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+func init() {
+	roles["hup-unregister"] = func(d *godaemonizer.Daemon) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		d.OnReloadSignal(func() error { os.Exit(4); return nil })
+		d.OnReloadSignal(nil)
+		ready(nil)
+		select {}
+	}
+}
+
+// TestReloadSignalUnregister checks that SIGHUP no longer reaches a
+// callback once OnReloadSignal(nil) unregistered it.
+func TestReloadSignalUnregister(t *testing.T) {
+	d := startDaemon(t, "hup-unregister", nil, nil)
+	if err := syscall.Kill(d.PID(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-d.Done():
+	case <-time.After(time.Second):
+		return // SIGHUP was ignored when the test started, and still is
+	}
+	status := d.ExitState().Sys().(syscall.WaitStatus)
+	if !status.Signaled() || status.Signal() != syscall.SIGHUP {
+		t.Fatalf("daemon ended with %v, want the default effect of SIGHUP", d.ExitState())
+	}
+}
@@ -0,0 +1,47 @@
+package daemonizer
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+)
+
+// DaemonID returns the random UUID that identifies one daemonization: the
+// parent generates a fresh one for each Daemonize, including each restart,
+// and hands it to the daemon. The library's log lines carry it as
+// "daemon_id" on both sides, so grepping for it follows one daemonization
+// end to end across the parent's and the daemon's logs. It is empty before
+// Daemonize, or in the daemon before WaitForParent. Called by either
+// process.
+func (d *Daemon) DaemonID() string {
+	return d.id
+}
+
+// newDaemonID sets a fresh DaemonID for a launch and returns it.
+func (d *Daemon) newDaemonID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 9562 variant
+	id := fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+	d.tagLogger(id)
+	return id
+}
+
+// tagLogger makes id the DaemonID and adds it to the library's log lines.
+func (d *Daemon) tagLogger(id string) {
+	if id == "" {
+		return
+	}
+	d.id = id
+	d.logger = d.untaggedLogger().With("daemon_id", id)
+}
+
+// untaggedLogger returns the logger given with WithLogger, without the
+// DaemonID of an earlier launch.
+func (d *Daemon) untaggedLogger() *slog.Logger {
+	if d.logBase == nil {
+		d.logBase = d.logger
+	}
+	return d.logBase
+}
@@ -0,0 +1,56 @@
+package daemonizer
+
+import "os"
+
+// Codecs of the params in the handoff, as Info reports them.
+const (
+	codecJSON     = "json"
+	codecJSONGzip = "json+gzip" // Config.CompressParams, for large params
+)
+
+// Info is a snapshot of how a daemon was started, e.g. for a "daemon
+// started" log line.
+type Info struct {
+	PID       int
+	ID        string // see DaemonID
+	Name      string // see WithName
+	Marker    string // the argument that marks the daemon's command line
+	Transport string // see TransportUsed
+	Protocol  int    // see ProtocolVersion
+	Codec     string // "json", or "json+gzip" for compressed params
+	Detached  bool   // whether there is a daemon, in a session of its own
+}
+
+// Info gathers what the handshake negotiated into one snapshot. In the
+// parent it describes the daemon started by the last successful Daemonize;
+// in the daemon, after WaitForParent, the daemon itself, as it sees the
+// same handshake. Every daemon runs in a session of its own, so Detached is
+// false only while there is none. Called by either process.
+func (d *Daemon) Info() Info {
+	info := Info{
+		ID:        d.id,
+		Name:      d.name,
+		Marker:    d.markerArg(),
+		Transport: d.transport,
+		Protocol:  d.version,
+		Codec:     d.codec,
+	}
+	if d.isDaemon {
+		if d.statusW != nil {
+			info.PID = os.Getpid()
+			info.Detached = true
+		}
+	} else if d.cmd != nil {
+		info.PID = d.PID()
+		info.Detached = true
+	}
+	return info
+}
+
+// codec returns the codec the params travel in.
+func (h *handoff) codec() string {
+	if h.ParamsGzip != nil {
+		return codecJSONGzip
+	}
+	return codecJSON
+}
@@ -0,0 +1,37 @@
+package daemonizer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// interruptible returns a context that is cancelled, with a cause wrapping
+// ErrInterrupted, when the parent receives SIGINT or SIGTERM, if
+// cfg.CancelOnSignal asks for it. stop restores the previous signal
+// handling.
+func interruptible(ctx context.Context, cfg *Config) (_ context.Context, stop func()) {
+	if cfg == nil || !cfg.CancelOnSignal {
+		return ctx, func() {}
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-sigs:
+			cancel(fmt.Errorf("%w: %v", ErrInterrupted, sig))
+		case <-done:
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(sigs)
+		close(done)
+		cancel(nil)
+	}
+}
@@ -0,0 +1,49 @@
+//go:build unix
+
+package daemonizer_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+func init() {
+	roles["slow-start"] = func(d *godaemonizer.Daemon) {
+		_, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		<-d.Cancelled()
+	}
+}
+
+// TestInterruptDuringHandshake checks that with CancelOnSignal a SIGINT
+// to the parent during the handshake aborts Daemonize and leaves no daemon
+// behind.
+func TestInterruptDuringHandshake(t *testing.T) {
+	d := godaemonizer.New(godaemonizer.WithName("slow-start"))
+	events := d.Events()
+	pid := make(chan int, 1)
+	go func() {
+		for e := range events {
+			if e.Kind == godaemonizer.EventSpawned {
+				pid <- e.PID
+				syscall.Kill(os.Getpid(), syscall.SIGINT)
+				return
+			}
+		}
+	}()
+
+	cfg := &godaemonizer.Config{CancelOnSignal: true, CancelGrace: time.Second}
+	err := d.Daemonize(context.Background(), nil, cfg)
+	if !errors.Is(err, godaemonizer.ErrInterrupted) {
+		t.Fatalf("Daemonize: %v, want %v", err, godaemonizer.ErrInterrupted)
+	}
+	waitExited(t, <-pid)
+}
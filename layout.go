@@ -0,0 +1,70 @@
+package daemonizer
+
+// fdLayout is where the daemon finds each file it inherits: the fd numbers
+// the handoff carries, and a name per fd, which DryRun reports.
+type fdLayout struct {
+	names                     []string // indexed by fd
+	tail, blob, stream, ready int      // 0 if not passed
+}
+
+// layoutFds lays out the daemon's fds for config in the order launch attaches
+// them: the standard streams, the param and status pipes, Config.ExtraFiles
+// and Listeners, then the startup tail's destinations, the binary params,
+// the param stream and the readiness pipe. stdio names the streams command
+// connects, which a PTY or a startup tail replace.
+func (d *Daemon) layoutFds(config *Config, stdio [3]string) fdLayout {
+	var cfg Config
+	if config != nil {
+		cfg = *config
+	}
+	param, status := "param_pipe", "status_pipe"
+	switch {
+	case cfg.ParamFile != "":
+		param = cfg.ParamFile
+	case cfg.Transport == TransportSocketpair:
+		param, status = "param_socket", "status_socket"
+	case cfg.Transport == TransportMemfd && !cfg.Control:
+		param = "param_memfd"
+	}
+	l := fdLayout{names: append(stdio[:], param, status)}
+	if cfg.StdinData != nil {
+		l.names[0] = "stdin_data"
+	}
+	if paramsOnStdin(&cfg) {
+		l.names[0], l.names[3] = param, "closed"
+	}
+	if cfg.StdoutPath != "" {
+		l.names[1] = cfg.StdoutPath
+	}
+	if cfg.StderrPath != "" {
+		l.names[2] = cfg.StderrPath
+	}
+	if cfg.AllocatePTY {
+		l.names[0], l.names[1], l.names[2] = "pty", "pty", "pty"
+	}
+
+	for _, f := range cfg.ExtraFiles {
+		l.names = append(l.names, fileName(f))
+	}
+	for _, ln := range cfg.Listeners {
+		l.names = append(l.names, "listener:"+ln.Addr().String())
+	}
+	if cfg.TailOutput != nil {
+		l.tail = len(l.names)
+		l.names = append(l.names, "tail:"+l.names[1], "tail:"+l.names[2])
+		l.names[1], l.names[2] = "tail_pipe", "tail_pipe"
+	}
+	if len(d.blobs) > 0 {
+		l.blob = len(l.names)
+		l.names = append(l.names, "binary_params")
+	}
+	if cfg.ParamStream != nil {
+		l.stream = len(l.names)
+		l.names = append(l.names, "param_stream")
+	}
+	if cfg.ReadinessViaFd {
+		l.ready = len(l.names)
+		l.names = append(l.names, "ready_pipe")
+	}
+	return l
+}
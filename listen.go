@@ -0,0 +1,68 @@
+package daemonizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// dialInterval is the pause between connection attempts while waiting for a
+// daemon to start listening.
+const dialInterval = 50 * time.Millisecond
+
+var (
+	ErrNotListening = errors.New("daemon is not accepting connections")
+	ErrNotReady     = errors.New("daemon did not become ready")
+)
+
+// DaemonizeAndWaitForPort daemonizes, then dials network/address until a
+// connection succeeds or timeout expires, confirming that the daemon is
+// actually accepting connections before the parent moves on. On timeout the
+// daemon is left running and an error wrapping ErrNotListening is returned;
+// call Stop to get rid of it. Called by the parent process.
+func (d *Daemon) DaemonizeAndWaitForPort(ctx context.Context, params any, cfg *Config, network, address string, timeout time.Duration) error {
+	if err := d.Daemonize(ctx, params, cfg); err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	var dialErr error
+	err := WaitReady(ctx, func(ctx context.Context) error {
+		conn, err := dialer.DialContext(ctx, network, address)
+		if err == nil {
+			conn.Close()
+		}
+		dialErr = err
+		return err
+	}, timeout, dialInterval)
+	if err != nil {
+		return fmt.Errorf("%w: %s %s: %v", ErrNotListening, network, address, dialErr)
+	}
+	return nil
+}
+
+// WaitReady calls check every interval until it returns nil or timeout
+// expires, for daemons that cannot take part in the startup handshake, e.g.
+// ones started by someone else. check decides what usable means, such as
+// dialing a socket or querying a health endpoint, and should honor ctx. On
+// timeout or cancellation it returns an error wrapping ErrNotReady and the
+// last error check returned.
+func WaitReady(ctx context.Context, check func(ctx context.Context) error, timeout, interval time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		err := check(ctx)
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", ErrNotReady, err)
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package daemonizer
+
+import (
+	"fmt"
+	"net"
+	"os"
+)
+
+// fileListener is implemented by the listeners whose socket can be passed to
+// the daemon, e.g. *net.TCPListener and *net.UnixListener.
+type fileListener interface {
+	File() (*os.File, error)
+}
+
+// listenerFiles duplicates the sockets of cfg.Listeners for the daemon to
+// inherit. The caller closes the returned files once the daemon has started.
+func listenerFiles(cfg *Config) ([]*os.File, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	files := make([]*os.File, 0, len(cfg.Listeners))
+	for i, l := range cfg.Listeners {
+		fl, ok := l.(fileListener)
+		if !ok {
+			closeFiles(files)
+			return nil, fmt.Errorf("listener %d (%T) cannot be passed to the daemon", i, l)
+		}
+		f, err := fl.File()
+		if err != nil {
+			closeFiles(files)
+			return nil, fmt.Errorf("listener %d: %w", i, err)
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+// Listeners returns the listeners the parent passed in Config.Listeners, in
+// order, rebuilt around the inherited sockets. Calling it again returns the
+// same listeners. Called by the daemon process after WaitForParent.
+func (d *Daemon) Listeners() ([]net.Listener, error) {
+	d.listenersOnce.Do(func() {
+		for i, f := range d.listenerFiles {
+			l, err := net.FileListener(f)
+			f.Close()
+			if err != nil {
+				d.listenersErr = fmt.Errorf("listener %d: %w", i, err)
+				return
+			}
+			d.listeners = append(d.listeners, l)
+		}
+	})
+	return d.listeners, d.listenersErr
+}
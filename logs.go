@@ -0,0 +1,80 @@
+package daemonizer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// openLogs opens cfg.StdoutPath and cfg.StderrPath for the daemon to append
+// its output to. The error names the path that failed, and on error nothing
+// is left open.
+func openLogs(cfg *Config) (stdout, stderr *os.File, err error) {
+	if cfg == nil {
+		return nil, nil, nil
+	}
+
+	if stdout, err = openLog("stdout", cfg.StdoutPath); err != nil {
+		return nil, nil, err
+	}
+	if stderr, err = openLog("stderr", cfg.StderrPath); err != nil {
+		closeIfOpen(stdout)
+		return nil, nil, err
+	}
+	return stdout, stderr, nil
+}
+
+// openLog opens the log file at path for stream, or returns nil if path is
+// empty.
+func openLog(stream, path string) (*os.File, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open %s file: %w", stream, err)
+	}
+	return f, nil
+}
+
+// absPath makes a log path absolute against the parent's working directory,
+// which the daemon may not share. It leaves an empty path, or one it cannot
+// resolve, as it is.
+func absPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// ReopenLogs opens the files named by Config.StdoutPath and StderrPath anew
+// and puts them in place of the daemon's stdout and stderr, so that output
+// goes to a fresh file after a log rotation tool moved the old one away.
+// The paths are resolved against the parent's working directory, as at
+// startup. A stream given as a Config.Stdout or Stderr file has no path and
+// is left alone. Wire it to SIGHUP, e.g. d.OnReloadSignal(d.ReopenLogs), or
+// to a control method. Called by the daemon process after WaitForParent.
+func (d *Daemon) ReopenLogs() error {
+	if d.logPaths == [2]string{} {
+		return errors.New("no Config.StdoutPath or StderrPath to reopen")
+	}
+	for i, stream := range []string{"stdout", "stderr"} {
+		f, err := openLog(stream, d.logPaths[i])
+		if err != nil {
+			return err
+		}
+		if f == nil {
+			continue
+		}
+		err = replaceFd(f, i+1)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("reopen %s: %w", stream, err)
+		}
+	}
+	return nil
+}
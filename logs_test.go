@@ -0,0 +1,101 @@
+package daemonizer_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+func init() {
+	roles["rotate"] = func(d *godaemonizer.Daemon) {
+		d.RegisterMethod("reopen", func(json.RawMessage) (any, error) {
+			if err := d.ReopenLogs(); err != nil {
+				return nil, err
+			}
+			fmt.Println("after rotation")
+			return nil, nil
+		})
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		fmt.Println("before rotation")
+		ready(nil)
+		select {}
+	}
+}
+
+// TestReopenLogs checks that ReopenLogs moves the daemon's output to a new
+// file once the old one has been renamed, as by a log rotation tool.
+func TestReopenLogs(t *testing.T) {
+	dir := t.TempDir()
+	log, rotated := filepath.Join(dir, "app.log"), filepath.Join(dir, "app.log.1")
+	d := startDaemon(t, "rotate", nil, &godaemonizer.Config{StdoutPath: log, Control: true})
+	if err := os.Rename(log, rotated); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Call("reopen", nil, nil); err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+
+	for path, want := range map[string]string{rotated: "before rotation\n", log: "after rotation\n"} {
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s holds %q, want %q", filepath.Base(path), got, want)
+		}
+	}
+}
+
+// TestLogPathErrors checks that a log file that cannot be opened fails
+// Daemonize with an error naming the stream and path, before anything is
+// started.
+func TestLogPathErrors(t *testing.T) {
+	dir := t.TempDir()
+	readOnly := filepath.Join(dir, "read-only")
+	if err := os.Mkdir(readOnly, 0o555); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		cfg  *godaemonizer.Config
+		path string
+	}{
+		{"directory", &godaemonizer.Config{StderrPath: dir}, dir},
+		{"missing directory", &godaemonizer.Config{StdoutPath: filepath.Join(dir, "missing", "log")}, filepath.Join(dir, "missing", "log")},
+		{"unwritable directory", &godaemonizer.Config{StdoutPath: filepath.Join(readOnly, "log")}, filepath.Join(readOnly, "log")},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.name == "unwritable directory" && os.Geteuid() == 0 {
+				t.Skip("root can write to any directory")
+			}
+			started := false
+			tc.cfg.PreStart = func(*exec.Cmd) error {
+				started = true
+				return nil
+			}
+			stream := "stdout"
+			if tc.cfg.StderrPath != "" {
+				stream = "stderr"
+			}
+
+			err := godaemonizer.New(godaemonizer.WithName("serve")).Daemonize(context.Background(), nil, tc.cfg)
+			if err == nil || !strings.Contains(err.Error(), stream) || !strings.Contains(err.Error(), tc.path) {
+				t.Errorf("Daemonize: %v, want an error naming %s and %s", err, stream, tc.path)
+			}
+			if started {
+				t.Error("the daemon was started")
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package daemonizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// DaemonizeMany starts count identical daemons with params and cfg, with d's
+// options, and waits for all of them to become ready concurrently. If any
+// fails, the rest are cancelled and the ones already running are stopped,
+// so either all count daemons are running or none is. d itself is only a
+// template and starts nothing. Each daemon gets its own copy of cfg, whose
+// PIDFile, ParamFile, ControlSocket, CrashFile and StatusFile have the
+// daemon's index appended, as in "app.pid.0". It returns ErrNotRestartable
+// for a cfg that can start only one daemon, and an error for a count below
+// 1. Called by the parent process.
+func (d *Daemon) DaemonizeMany(ctx context.Context, count int, params any, cfg *Config) ([]*Daemon, error) {
+	if d.isDaemon || daemonProcess.Load() {
+		return nil, ErrAlreadyDaemon
+	}
+	if count < 1 {
+		return nil, fmt.Errorf("daemon count %d is less than 1", count)
+	}
+	if count > 1 {
+		if err := cfg.reusable(); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	daemons := make([]*Daemon, count)
+	errs := make([]error, count)
+	var wg sync.WaitGroup
+	for i := range count {
+		daemons[i] = d.sibling()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := daemons[i].Daemonize(ctx, params, instanceConfig(cfg, i)); err != nil {
+				errs[i] = fmt.Errorf("daemon %d: %w", i, err)
+				cancel() // fail fast: abort the startups still in progress
+			}
+		}()
+	}
+	wg.Wait()
+
+	// the first real failure, not the cancellations it caused
+	var failed error
+	for _, err := range errs {
+		if err != nil && (failed == nil || errors.Is(failed, context.Canceled)) {
+			failed = err
+		}
+	}
+	if failed == nil {
+		return daemons, nil
+	}
+
+	for i, dm := range daemons {
+		if errs[i] == nil {
+			dm.Stop(cancelGrace(cfg))
+		}
+	}
+	return nil, failed
+}
+
+// instanceConfig returns the copy of cfg for daemon i of DaemonizeMany,
+// with a file of its own wherever a daemon writes or listens on one.
+func instanceConfig(cfg *Config, i int) *Config {
+	if cfg == nil {
+		return nil
+	}
+	c := *cfg
+	for _, path := range []*string{&c.PIDFile, &c.ParamFile, &c.ControlSocket, &c.CrashFile, &c.StatusFile} {
+		if *path != "" {
+			*path += "." + strconv.Itoa(i)
+		}
+	}
+	return &c
+}
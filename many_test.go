@@ -0,0 +1,104 @@
+package daemonizer_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+func init() {
+	// in the directory it is given, the first instance to claim "failed"
+	// fails its startup once the others are up; the others write a file
+	// named after their pid and run
+	roles["one-fails"] = func(d *godaemonizer.Daemon) {
+		var dir string
+		ready, err := d.WaitForParent(&dir)
+		if err != nil {
+			os.Exit(1)
+		}
+		if os.Mkdir(filepath.Join(dir, "failed"), 0o755) == nil {
+			time.Sleep(500 * time.Millisecond)
+			ready(errors.New("instance failed"))
+			os.Exit(1)
+		}
+		os.WriteFile(filepath.Join(dir, strconv.Itoa(os.Getpid())), nil, 0o644)
+		ready(nil)
+		select {}
+	}
+}
+
+// TestDaemonizeManyFiles checks that every daemon of a pool writes its own
+// PID file.
+func TestDaemonizeManyFiles(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "app.pid")
+	d := godaemonizer.New(godaemonizer.WithName("serve"))
+	daemons, err := d.DaemonizeMany(context.Background(), 3, nil, &godaemonizer.Config{PIDFile: pidFile})
+	if err != nil {
+		t.Fatalf("DaemonizeMany: %v", err)
+	}
+	for i, dm := range daemons {
+		t.Cleanup(func() { dm.Kill() })
+		b, err := os.ReadFile(fmt.Sprintf("%s.%d", pidFile, i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pid, _ := strconv.Atoi(strings.TrimSpace(string(b))); pid != dm.PID() {
+			t.Errorf("PID file of daemon %d holds %q, want %d", i, b, dm.PID())
+		}
+	}
+}
+
+func TestDaemonizeManyOneShotConfig(t *testing.T) {
+	d := godaemonizer.New(godaemonizer.WithName("serve"))
+	cfg := &godaemonizer.Config{ParamStream: strings.NewReader("stream")}
+	if _, err := d.DaemonizeMany(context.Background(), 2, nil, cfg); !errors.Is(err, godaemonizer.ErrNotRestartable) {
+		t.Fatalf("DaemonizeMany: %v, want %v", err, godaemonizer.ErrNotRestartable)
+	}
+}
+
+// TestDaemonizeManyPartialFailure checks that when one daemon of a pool
+// fails, the ones already running are stopped.
+func TestDaemonizeManyPartialFailure(t *testing.T) {
+	dir := t.TempDir()
+	d := godaemonizer.New(godaemonizer.WithName("one-fails"))
+	daemons, err := d.DaemonizeMany(context.Background(), 3, dir, nil)
+	for _, dm := range daemons {
+		dm.Kill()
+	}
+	if err == nil || !strings.Contains(err.Error(), "instance failed") {
+		t.Fatalf("DaemonizeMany: %v, want the failure of one daemon", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := 0
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		started++
+		waitExited(t, pid)
+	}
+	if started != 2 {
+		t.Errorf("%d daemons started besides the failed one, want 2", started)
+	}
+}
+
+func TestDaemonizeManyCount(t *testing.T) {
+	d := godaemonizer.New(godaemonizer.WithName("serve"))
+	for _, count := range []int{0, -1} {
+		if daemons, err := d.DaemonizeMany(context.Background(), count, nil, nil); err == nil {
+			t.Errorf("DaemonizeMany with count %d: %d daemons, want an error", count, len(daemons))
+		}
+	}
+}
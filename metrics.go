@@ -0,0 +1,32 @@
+package daemonizer
+
+import "time"
+
+// Metrics receives the duration of each phase of Daemonize, e.g. to find
+// out whether a slow filesystem or a loaded machine delays startup. Each
+// method is called at most once per Daemonize, and only for phases that
+// completed.
+type Metrics interface {
+	// ObserveSpawnDuration reports how long starting the child process
+	// took, including any retries.
+	ObserveSpawnDuration(time.Duration)
+	// ObserveHandshakeDuration reports how long sending the params took.
+	ObserveHandshakeDuration(time.Duration)
+	// ObserveReadinessDuration reports how long the daemon took, after
+	// receiving its params, to report readiness or failure.
+	ObserveReadinessDuration(time.Duration)
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) ObserveSpawnDuration(time.Duration)     {}
+func (nopMetrics) ObserveHandshakeDuration(time.Duration) {}
+func (nopMetrics) ObserveReadinessDuration(time.Duration) {}
+
+// metrics returns cfg's Metrics, or a no-op implementation.
+func metrics(cfg *Config) Metrics {
+	if cfg == nil || cfg.Metrics == nil {
+		return nopMetrics{}
+	}
+	return cfg.Metrics
+}
@@ -0,0 +1,11 @@
+package daemonizer
+
+import (
+	"os"
+	"strconv"
+)
+
+// setOOMScoreAdj sets the calling process's OOM killer score adjustment.
+func setOOMScoreAdj(adj int) error {
+	return os.WriteFile("/proc/self/oom_score_adj", []byte(strconv.Itoa(adj)), 0)
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package daemonizer
+
+import "errors"
+
+func setOOMScoreAdj(adj int) error {
+	return errors.New("OOM score adjustment is only supported on Linux")
+}
@@ -0,0 +1,89 @@
+package daemonizer
+
+import (
+	"io"
+	"log/slog"
+	"time"
+)
+
+// Option configures a Daemon at construction time.
+type Option func(*Daemon)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// WithLogger sets the logger used for the library's own diagnostics.
+// By default nothing is logged.
+func WithLogger(logger *slog.Logger) Option {
+	return func(d *Daemon) {
+		if logger != nil {
+			d.logger = logger
+		}
+	}
+}
+
+// PreserveOSArgs leaves os.Args untouched in the daemon, marker included.
+// Use Args to get the stripped list, e.g. to feed a flag parser.
+func PreserveOSArgs() Option {
+	return func(d *Daemon) {
+		d.preserveArgs = true
+	}
+}
+
+// WithMarker replaces the command-line argument that tells the daemon apart
+// from the parent (default "--__daemon__"), e.g. when it collides with one of
+// the program's own flags. The parent injects and the daemon detects the
+// same marker, so both must be built with the same option; since they are
+// the same binary, passing a constant is enough.
+func WithMarker(marker string) Option {
+	return func(d *Daemon) {
+		if marker != "" {
+			d.marker = marker
+		}
+	}
+}
+
+// WithShutdownTimeout bounds how long the OnShutdown hooks may take in
+// total (default 10s). Their context expires after timeout.
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(d *Daemon) {
+		if timeout > 0 {
+			d.shutdownTimeout = timeout
+		}
+	}
+}
+
+// WithParamsTimeout bounds how long WaitForParent waits for the parent's
+// params (default 30s), so a daemon whose parent died before sending them
+// exits with ErrParamsTimeout instead of hanging forever.
+func WithParamsTimeout(timeout time.Duration) Option {
+	return func(d *Daemon) {
+		if timeout > 0 {
+			d.paramsTimeout = timeout
+		}
+	}
+}
+
+// WithMaxParamBytes caps the size of the handoff WaitForParent accepts
+// from the parent (default 64 MiB), params and secrets included, so a buggy
+// parent cannot make the daemon decode an enormous blob into memory. The cap
+// applies to compressed params once decompressed too. A larger handoff
+// fails the startup with ErrParamsTooLarge.
+func WithMaxParamBytes(n int64) Option {
+	return func(d *Daemon) {
+		if n > 0 {
+			d.maxParamBytes = n
+		}
+	}
+}
+
+// WithName makes the Daemon launch a named daemon, so that one binary can run
+// several daemons in different roles (e.g. "worker", "scheduler"). The marker
+// becomes "--__daemon__=name", and the daemon learns its role from Name.
+// In the daemon the name always comes from the command line, so New can be
+// called without this option there. Each role needs its own Daemon in the
+// parent.
+func WithName(name string) Option {
+	return func(d *Daemon) {
+		d.name = name
+	}
+}
@@ -0,0 +1,84 @@
+package daemonizer_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+func init() {
+	roles["keep-args"] = func(d *godaemonizer.Daemon) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		if slices.Equal(os.Args, d.Args()) || !slices.Equal(os.Args, d.OriginalArgs()) {
+			ready(fmt.Errorf("os.Args = %q, want %q unchanged", os.Args, d.OriginalArgs()))
+			return
+		}
+		ready(nil)
+	}
+	keepArgs["keep-args"] = true
+
+	roles["custom-marker"] = func(d *godaemonizer.Daemon) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		if !slices.Contains(d.OriginalArgs(), testMarker+"=custom-marker") {
+			ready(fmt.Errorf("started with %q, want the custom marker", d.OriginalArgs()))
+			return
+		}
+		ready(nil)
+	}
+
+	roles["capped-params"] = func(*godaemonizer.Daemon) {
+		serve(godaemonizer.New(godaemonizer.WithMaxParamBytes(4096)))
+	}
+}
+
+// TestPreserveOSArgs checks that with PreserveOSArgs the daemon's os.Args
+// keep the marker, while Args drops it.
+func TestPreserveOSArgs(t *testing.T) {
+	startDaemon(t, "keep-args", nil, nil)
+}
+
+// TestWithMarker checks that the parent injects a custom marker and that a
+// daemon built with the same option detects it.
+func TestWithMarker(t *testing.T) {
+	d := godaemonizer.New(godaemonizer.WithMarker(testMarker), godaemonizer.WithName("custom-marker"))
+	plan, err := d.DryRun(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(plan.Args, testMarker+"=custom-marker") {
+		t.Fatalf("planned args %q lack the custom marker", plan.Args)
+	}
+	t.Cleanup(func() { d.Kill() })
+	if err := d.Daemonize(context.Background(), nil, nil); err != nil {
+		t.Fatalf("Daemonize: %v", err)
+	}
+}
+
+// TestMaxParamBytes checks that a daemon accepts params under its
+// WithMaxParamBytes cap and reports a startup error for params over it.
+func TestMaxParamBytes(t *testing.T) {
+	startDaemon(t, "capped-params", map[string]any{"padding": strings.Repeat("x", 1024)}, nil)
+
+	d := godaemonizer.New(godaemonizer.WithName("capped-params"))
+	t.Cleanup(func() { d.Kill() })
+	err := d.Daemonize(context.Background(), map[string]any{"padding": strings.Repeat("x", 1<<20)}, nil)
+	var de *godaemonizer.DaemonError
+	if !errors.As(err, &de) {
+		t.Fatalf("Daemonize with oversized params: %v, want a DaemonError", err)
+	}
+	if !strings.Contains(de.Message, godaemonizer.ErrParamsTooLarge.Error()) {
+		t.Errorf("daemon error %q does not mention %q", de.Message, godaemonizer.ErrParamsTooLarge)
+	}
+}
@@ -0,0 +1,181 @@
+package daemonizer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// ParamCheck controls whether Daemonize verifies that params survive the
+// JSON round trip to the daemon unchanged.
+type ParamCheck int
+
+const (
+	ParamCheckOff    ParamCheck = iota // no verification (default)
+	ParamCheckWarn                     // log a warning through the Daemon's logger
+	ParamCheckStrict                   // fail Daemonize with ErrParamsLossy
+)
+
+// checkParams applies cfg's ParamCheck mode to the serialized params.
+func (d *Daemon) checkParams(params any, payload []byte, cfg *Config) error {
+	if cfg == nil || cfg.ParamCheck == ParamCheckOff {
+		return nil
+	}
+
+	err := checkRoundTrip(params, payload)
+	if err == nil || cfg.ParamCheck == ParamCheckStrict {
+		return err
+	}
+	d.logger.Warn("params changed in JSON round trip", "error", err)
+	return nil
+}
+
+// OnParams registers fn to run with the params, decoded into a map, as
+// soon as WaitForParent has read them and set up the daemon, which makes it
+// a single entry point for the daemon's logic. An error from fn fails the
+// startup: WaitForParent reports it to the parent and returns it. Register
+// it before WaitForParent. Called by the daemon process.
+func (d *Daemon) OnParams(fn func(params map[string]any) error) {
+	d.onParams = fn
+}
+
+// runOnParams decodes raw for the OnParams handler and calls it.
+func (d *Daemon) runOnParams(raw json.RawMessage) error {
+	var params map[string]any
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return fmt.Errorf("decode params for OnParams: %w", err)
+	}
+	return d.onParams(params)
+}
+
+// handoffLimit is io.LimitReader for the handoff, which remembers running
+// into the limit, so that the daemon can tell it from malformed JSON; a
+// negative n lifts the limit.
+type handoffLimit struct {
+	r        io.Reader
+	n        int64
+	exceeded bool
+}
+
+func (l *handoffLimit) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return l.r.Read(p)
+	}
+	if l.n == 0 {
+		l.exceeded = true
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+// checksum returns the SHA-256 of the params and secrets in h, each
+// prefixed with its length so that no bytes can move from one to the
+// other unnoticed. It covers the params uncompressed; gzip checks the
+// compressed form itself.
+func (h *handoff) checksum() string {
+	sum := sha256.New()
+	for _, b := range [][]byte{h.Params, h.Secrets} {
+		binary.Write(sum, binary.BigEndian, uint64(len(b)))
+		sum.Write(b)
+	}
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// verify checks the handoff against the checksum the parent sent with it.
+// A parent that predates checksums sent none.
+func (h *handoff) verify() error {
+	if h.Sum == "" || h.Sum == h.checksum() {
+		return nil
+	}
+	return fmt.Errorf("%w: checksum mismatch", ErrParamCorrupted)
+}
+
+// corrupted tells a handoff that was cut short or garbled from other
+// decode failures.
+func corrupted(err error) error {
+	var syntax *json.SyntaxError
+	switch {
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return fmt.Errorf("%w: truncated handoff: %w", ErrParamCorrupted, err)
+	case errors.As(err, &syntax):
+		return fmt.Errorf("%w: %w", ErrParamCorrupted, err)
+	}
+	return err
+}
+
+// RoundTripParams sends params through the same JSON encoding and decoding
+// as Daemonize and WaitForParent, without starting anything, and returns
+// what a daemon decoding into a map would receive. It makes the lossy parts
+// visible in tests: numbers come back as float64, and values with custom
+// JSON methods, such as time.Time, as their JSON form.
+func RoundTripParams(params map[string]any) (map[string]any, error) {
+	payload, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("encode params: %w", err)
+	}
+	var out map[string]any
+	if err := json.Unmarshal(payload, &out); err != nil {
+		return nil, fmt.Errorf("decode params: %w", err)
+	}
+	return out, nil
+}
+
+// checkRoundTrip decodes payload into a fresh value of params' type, as the
+// daemon would, and compares its encoding with the original payload. Numbers
+// are compared by their literal text so that precision loss (e.g. an int64
+// above 2^53 passing through an interface{} as float64) is detected.
+func checkRoundTrip(params any, payload []byte) error {
+	if params == nil {
+		return nil
+	}
+
+	t := reflect.TypeOf(params)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	dest := reflect.New(t).Interface()
+
+	if err := json.Unmarshal(payload, dest); err != nil {
+		return fmt.Errorf("%w: decode: %v", ErrParamsLossy, err)
+	}
+	again, err := json.Marshal(dest)
+	if err != nil {
+		return fmt.Errorf("%w: re-encode: %v", ErrParamsLossy, err)
+	}
+
+	before, err := decodeExact(payload)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrParamsLossy, err)
+	}
+	after, err := decodeExact(again)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrParamsLossy, err)
+	}
+	if !reflect.DeepEqual(before, after) {
+		return ErrParamsLossy
+	}
+	return nil
+}
+
+// decodeExact decodes JSON generically, keeping numbers as json.Number.
+func decodeExact(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
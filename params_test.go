@@ -0,0 +1,111 @@
+package daemonizer_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+func init() {
+	roles["on-params"] = func(d *godaemonizer.Daemon) {
+		var got map[string]any
+		d.OnParams(func(params map[string]any) error {
+			if params["reject"] == true {
+				return errors.New("params rejected")
+			}
+			got = params
+			return nil
+		})
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		d.SetReadyData(got)
+		ready(nil)
+	}
+	roles["corrupt-params"] = func(d *godaemonizer.Daemon) {
+		_, err := d.WaitForParent(nil)
+		fmt.Fprintln(os.Stderr, err)
+		if !errors.Is(err, godaemonizer.ErrParamCorrupted) {
+			os.Exit(1)
+		}
+		os.Exit(5)
+	}
+}
+
+// TestCorruptedParams checks that a handoff cut short, garbled or not
+// matching its checksum fails the daemon with ErrParamCorrupted, which it
+// reports to the parent.
+func TestCorruptedParams(t *testing.T) {
+	for _, handoff := range []string{
+		`{"params":{"n":1`,
+		`{"params":{"n":@}}`,
+		`{"params":{"n":1},"sum":"0123"}`,
+	} {
+		paramR, paramW, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		statusR, statusW, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		cmd := exec.CommandContext(ctx, os.Args[0], "--__daemon__=corrupt-params")
+		cmd.ExtraFiles = []*os.File{paramR, statusW}
+		var stderr strings.Builder
+		cmd.Stderr = &stderr
+		err = cmd.Start()
+		paramR.Close()
+		statusW.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(paramW, handoff)
+		paramW.Close()
+		report, _ := io.ReadAll(statusR)
+		statusR.Close()
+		err = cmd.Wait()
+		cancel()
+
+		var exit *exec.ExitError
+		if !errors.As(err, &exit) || exit.ExitCode() != 5 {
+			t.Errorf("handoff %s: %v, stderr %q; want ErrParamCorrupted", handoff, err, stderr.String())
+		} else if !strings.Contains(string(report), godaemonizer.ErrParamCorrupted.Error()) {
+			t.Errorf("handoff %s: status %q does not report %q", handoff, report, godaemonizer.ErrParamCorrupted)
+		}
+	}
+}
+
+// TestOnParams checks that the OnParams handler runs with the params before
+// WaitForParent returns, and that its error fails the startup.
+func TestOnParams(t *testing.T) {
+	params := map[string]any{"name": "worker", "n": 2.0, "tags": []any{"a", "b"}}
+	d := godaemonizer.New(godaemonizer.WithName("on-params"))
+	t.Cleanup(func() { d.Kill() })
+	var got map[string]any
+	if err := d.DaemonizeWithData(context.Background(), params, nil, &got); err != nil {
+		t.Fatalf("Daemonize: %v", err)
+	}
+	if !reflect.DeepEqual(got, params) {
+		t.Errorf("OnParams got %v, want %v", got, params)
+	}
+
+	rejecting := godaemonizer.New(godaemonizer.WithName("on-params"))
+	t.Cleanup(func() { rejecting.Kill() })
+	err := rejecting.Daemonize(context.Background(), map[string]any{"reject": true}, nil)
+	var de *godaemonizer.DaemonError
+	if !errors.As(err, &de) || !strings.Contains(de.Message, "params rejected") {
+		t.Errorf("Daemonize with params OnParams rejects: %v, want its error", err)
+	}
+}
@@ -0,0 +1,74 @@
+package daemonizer
+
+import (
+	"io"
+	"os"
+	"os/exec"
+)
+
+// ParamStream returns the stream of Config.ParamStream, for configs that
+// arrive in chunks or as follow-on updates rather than as one map, e.g.
+// JSON values read one after another with a json.Decoder. It reaches EOF
+// when the parent's reader does. It returns nil without a stream, or
+// before WaitForParent. Called by the daemon process.
+func (d *Daemon) ParamStream() io.Reader {
+	if d.stream == nil {
+		return nil
+	}
+	return d.stream
+}
+
+// paramStream is the parent's side of Config.ParamStream: a pipe the
+// stream is copied into.
+type paramStream struct {
+	src  io.Reader
+	r, w *os.File
+}
+
+// openParamStream opens the pipe for cfg.ParamStream, or returns nil
+// without one.
+func openParamStream(cfg *Config) (*paramStream, error) {
+	if cfg == nil || cfg.ParamStream == nil {
+		return nil, nil
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, pipeError("param stream pipe", err)
+	}
+	return &paramStream{src: cfg.ParamStream, r: r, w: w}, nil
+}
+
+// attach passes the stream's read end after all other inherited files.
+func (s *paramStream) attach(cmd *exec.Cmd) {
+	if s == nil {
+		return
+	}
+	cmd.ExtraFiles = append(cmd.ExtraFiles, s.r)
+}
+
+// send copies the stream to the daemon from a goroutine and closes the
+// pipe once the stream ends, or once the daemon has gone and a write
+// fails. Call it once the daemon has started.
+func (s *paramStream) send() {
+	if s == nil {
+		return
+	}
+	s.r.Close()
+	s.r = nil
+	w := s.w
+	s.w = nil
+	go func() {
+		defer w.Close()
+		io.Copy(w, s.src)
+	}()
+}
+
+// release closes the parent's ends of the pipe, unless send took them
+// over.
+func (s *paramStream) release() {
+	if s == nil {
+		return
+	}
+	closeIfOpen(s.r)
+	closeIfOpen(s.w)
+}
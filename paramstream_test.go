@@ -0,0 +1,67 @@
+package daemonizer_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+// streamUpdate is a config update the "stream" daemon reads from its
+// param stream.
+type streamUpdate struct {
+	Seq  int    `json:"seq"`
+	Mode string `json:"mode"`
+}
+
+func init() {
+	roles["stream"] = func(d *godaemonizer.Daemon) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		ready(nil)
+		// each update, then the end of the stream, goes back as an event
+		dec := json.NewDecoder(d.ParamStream())
+		for {
+			var u streamUpdate
+			if err := dec.Decode(&u); errors.Is(err, io.EOF) {
+				d.SendEvent("eof")
+				return
+			} else if err != nil {
+				d.SendEvent(err.Error())
+				return
+			}
+			d.SendEvent(u)
+		}
+	}
+}
+
+// TestParamStream checks that the daemon reads the updates of its param
+// stream one by one, as the parent writes them, and then sees its end.
+func TestParamStream(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	d := startDaemon(t, "stream", nil, &godaemonizer.Config{ParamStream: r, KeepOutputOpen: true})
+	out := d.Output()
+
+	for seq := range 3 {
+		want := streamUpdate{Seq: seq, Mode: fmt.Sprintf("mode-%d", seq)}
+		if err := json.NewEncoder(w).Encode(want); err != nil {
+			t.Fatal(err)
+		}
+		// the daemon must answer before the next update is written
+		var got streamUpdate
+		if err := json.Unmarshal(<-out, &got); err != nil || got != want {
+			t.Fatalf("daemon read %+v (%v), want %+v", got, err, want)
+		}
+	}
+	w.Close()
+	if event := string(<-out); event != `"eof"` {
+		t.Errorf("after the stream ended the daemon sent %s, want \"eof\"", event)
+	}
+}
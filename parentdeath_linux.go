@@ -0,0 +1,23 @@
+package daemonizer
+
+import (
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// watchParent has the kernel send SIGTERM to the daemon when the process
+// that started it dies. A parent that died before the request took effect
+// is caught by checking that the daemon has not been reparented already.
+// There is nothing to poll, so interval is unused.
+func watchParent(ppid int, interval time.Duration) error {
+	if err := unix.Prctl(unix.PR_SET_PDEATHSIG, uintptr(syscall.SIGTERM), 0, 0, 0); err != nil {
+		return err
+	}
+	if os.Getppid() != ppid {
+		return syscall.Kill(os.Getpid(), syscall.SIGTERM)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+//go:build !linux
+
+package daemonizer
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// watchParent polls every interval for the death of the process that
+// started the daemon, noticed as the daemon being reparented or the parent
+// no longer existing, and then sends the daemon SIGTERM. Where signals
+// cannot be sent to oneself, as on Windows, the daemon exits instead.
+func watchParent(ppid int, interval time.Duration) error {
+	go func() {
+		for os.Getppid() == ppid && ProcessAlive(ppid) {
+			time.Sleep(interval)
+		}
+		self, err := os.FindProcess(os.Getpid())
+		if err == nil {
+			err = self.Signal(syscall.SIGTERM)
+		}
+		if err != nil {
+			os.Exit(1)
+		}
+	}()
+	return nil
+}
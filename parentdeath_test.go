@@ -0,0 +1,53 @@
+//go:build unix
+
+package daemonizer_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+func init() {
+	// daemonizes with DieWithParent, prints the daemon's pid and waits to
+	// be killed
+	parents["die-with-parent"] = func() {
+		d := godaemonizer.New(godaemonizer.WithName("serve"))
+		if err := d.Daemonize(context.Background(), nil, &godaemonizer.Config{DieWithParent: true}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println(d.PID())
+		select {}
+	}
+}
+
+// TestDieWithParent checks that a daemon started with DieWithParent exits
+// when its parent is killed.
+func TestDieWithParent(t *testing.T) {
+	r, w := io.Pipe()
+	parent := startParent(t, "die-with-parent", w)
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil {
+		t.Fatalf("read the daemon's pid: %v", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil {
+		t.Fatalf("parent printed %q, want the daemon's pid", line)
+	}
+	t.Cleanup(func() { syscall.Kill(pid, syscall.SIGKILL) })
+
+	if err := syscall.Kill(pid, 0); err != nil {
+		t.Fatalf("daemon not running while the parent is: %v", err)
+	}
+	parent.Process.Kill()
+	waitExited(t, pid)
+}
@@ -0,0 +1,117 @@
+package daemonizer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+)
+
+// writePIDFile records the calling process's PID in path, atomically, and
+// durably with sync; see writeFileAtomic.
+func writePIDFile(path string, sync bool) error {
+	return writeFileAtomic(path, []byte(strconv.Itoa(os.Getpid())+"\n"), sync)
+}
+
+// writeFileAtomic writes a temporary file and renames it into place, so
+// readers see either the old content or the whole new one, never an empty
+// or partial file. With sync, the file is fsynced before the rename and its
+// directory after it, so the new content survives a power loss.
+func writeFileAtomic(path string, data []byte, sync bool) error {
+	tmp := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+	if err := writeFile(tmp, data, sync); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if sync {
+		return syncDir(filepath.Dir(path))
+	}
+	return nil
+}
+
+// writeFile is os.WriteFile, with an fsync before the close if sync is set.
+func writeFile(path string, data []byte, sync bool) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil && sync {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// syncDir fsyncs a directory, which makes a rename in it durable. Windows
+// cannot sync a directory and has nothing to do.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// readPIDFile returns the PID recorded in path.
+func readPIDFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid PID file %s: %w", path, err)
+	}
+	return pid, nil
+}
+
+// checkPIDFile fails with ErrDaemonAlreadyRunning if cfg.PIDFile names a
+// live process other than the caller, which only holds it when handing over
+// to a successor with ReExec. A missing, unreadable or stale file does not
+// block a start; the daemon overwrites it.
+func checkPIDFile(cfg *Config) error {
+	if cfg == nil || cfg.PIDFile == "" {
+		return nil
+	}
+	pid, err := readPIDFile(cfg.PIDFile)
+	if err != nil || pid == os.Getpid() || !ProcessAlive(pid) {
+		return nil
+	}
+	return fmt.Errorf("%w: pid %d (%s)", ErrDaemonAlreadyRunning, pid, cfg.PIDFile)
+}
+
+// RemovePIDFile removes the daemon's PID file, if Config.PIDFile set one and
+// it still holds this process's PID, so a successor's file is never
+// removed. OnShutdown and a failed startup remove it automatically; call it
+// on any other way out, e.g. deferred in main.
+// Called by the daemon process.
+func (d *Daemon) RemovePIDFile() error {
+	if d.pidFile == "" {
+		return nil
+	}
+	pid, err := readPIDFile(d.pidFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if pid != os.Getpid() {
+		return nil
+	}
+	return os.Remove(d.pidFile)
+}
@@ -0,0 +1,70 @@
+package daemonizer_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+func init() {
+	roles["shutdown"] = func(d *godaemonizer.Daemon) {
+		d.OnShutdown(func(context.Context) error { return nil })
+		serve(d)
+	}
+}
+
+// TestPIDFileStopStart checks the sequence a CLI's "stop", "status" and
+// "start" rely on: the PID file is there once Daemonize returns, gone once
+// Stop returns, and a new daemon can start right away.
+func TestPIDFileStopStart(t *testing.T) {
+	cfg := &godaemonizer.Config{PIDFile: filepath.Join(t.TempDir(), "app.pid")}
+	d := startDaemon(t, "shutdown", nil, cfg)
+	if _, err := os.Stat(cfg.PIDFile); err != nil {
+		t.Fatalf("PID file after Daemonize: %v", err)
+	}
+	if err := d.Stop(5 * time.Second); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if _, err := os.Stat(cfg.PIDFile); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("PID file after Stop: %v, want it removed", err)
+	}
+	startDaemon(t, "shutdown", nil, cfg)
+}
+
+// TestFsyncFiles checks, as far as a test can, that FsyncFiles still leaves
+// complete PID and status files behind.
+func TestFsyncFiles(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &godaemonizer.Config{
+		PIDFile:    filepath.Join(dir, "app.pid"),
+		StatusFile: filepath.Join(dir, "status.json"),
+		FsyncFiles: true,
+	}
+	d := startDaemon(t, "serve", nil, cfg)
+
+	pid, err := os.ReadFile(cfg.PIDFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(pid)); got != strconv.Itoa(d.PID()) {
+		t.Errorf("PID file holds %q, want %d", got, d.PID())
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, err := os.ReadFile(cfg.StatusFile)
+		if err == nil && len(status) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("status file %q, %v; want it written", status, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
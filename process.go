@@ -0,0 +1,188 @@
+package daemonizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// killTimeout bounds how long Stop waits for the daemon after SIGKILL.
+const killTimeout = 2 * time.Second
+
+// Stop asks the daemon to exit with SIGTERM and waits up to grace for it.
+// If the daemon is still running after grace, it is sent SIGKILL and Stop
+// returns ErrStopKilled once it is gone, or ErrStopTimeout if it is still
+// there after a short final window. After a clean exit, ShutdownAcked tells
+// whether the daemon's OnShutdown hooks completed. A daemon that is already
+// gone, e.g. because it was stopped before, needs no stopping and Stop
+// returns nil; not being allowed to signal it is an error. It returns
+// ErrNotParentProcess in the daemon.
+// Called by the parent process after a successful Daemonize.
+func (d *Daemon) Stop(grace time.Duration) error {
+	if d.isDaemon {
+		return ErrNotParentProcess
+	}
+	if d.isClosed() {
+		return ErrClosed
+	}
+	if d.cmd == nil {
+		return ErrNotStarted
+	}
+	d.reap()
+
+	if err := probe(d.proc); errors.Is(err, os.ErrProcessDone) {
+		// the reaper may still be at work; a Restart must not reset the
+		// Daemon under it
+		<-d.exited
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("signal daemon: %w", err)
+	}
+	if err := d.proc.Signal(syscall.SIGTERM); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return fmt.Errorf("signal daemon: %w", err)
+	}
+
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+
+	select {
+	case <-d.exited:
+		d.awaitAck()
+		return nil
+	case <-timer.C:
+	}
+
+	if err := d.proc.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return fmt.Errorf("kill daemon: %w", err)
+	}
+
+	timer.Reset(killTimeout)
+	select {
+	case <-d.exited:
+		return ErrStopKilled
+	case <-timer.C:
+		return ErrStopTimeout
+	}
+}
+
+// Kill terminates the daemon immediately with SIGKILL, or TerminateProcess
+// on Windows, without giving it a chance to clean up, and waits for it to
+// be reaped. Use it for a daemon that does not respond to Stop. It returns
+// ErrStopTimeout if the daemon is still there after a short window.
+// Called by the parent process after a successful Daemonize.
+func (d *Daemon) Kill() error {
+	if d.isDaemon {
+		return ErrNotParentProcess
+	}
+	if d.isClosed() {
+		return ErrClosed
+	}
+	if d.cmd == nil {
+		return ErrNotStarted
+	}
+	d.reap()
+
+	if err := d.proc.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return fmt.Errorf("kill daemon: %w", err)
+	}
+
+	timer := time.NewTimer(killTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-d.exited:
+		return nil
+	case <-timer.C:
+		return ErrStopTimeout
+	}
+}
+
+// reap starts waiting for the daemon in the background, once. exited is
+// closed when the daemon has exited and been reaped; from then on, events
+// left unread do not keep the channel's reader blocked.
+func (d *Daemon) reap() {
+	d.waitOnce.Do(func() {
+		// the goroutine keeps to this daemon's fields, which reset clears
+		// for the next one
+		cmd, proc, channel, exited := d.cmd, d.proc, d.channel, d.exited
+		go func() {
+			d.waitErr = cmd.Wait()
+			if proc != cmd.Process {
+				awaitGone(proc)
+				// Stop and Kill still probe the handle, unless the
+				// parent let go of the daemon
+				if d.isClosed() {
+					proc.Release()
+				}
+			}
+			if channel != nil {
+				channel.abandon()
+			}
+			close(exited)
+		}()
+	})
+}
+
+// goneInterval is how often awaitGone checks on the daemon.
+const goneInterval = 100 * time.Millisecond
+
+// awaitGone blocks until p no longer exists. It is for a daemon that is not
+// the parent's child, e.g. after a double fork, and so cannot be waited for.
+func awaitGone(p *os.Process) {
+	for !errors.Is(probe(p), os.ErrProcessDone) {
+		time.Sleep(goneInterval)
+	}
+}
+
+// Done returns a channel that is closed when the daemon exits. It returns
+// nil, which blocks forever, if no daemon was started. Called by the parent
+// process, which must stay alive to observe the exit.
+func (d *Daemon) Done() <-chan struct{} {
+	if d.cmd == nil {
+		return nil
+	}
+	d.reap()
+	return d.exited
+}
+
+// ExitState returns the daemon's exit status once Done is closed, or nil
+// while it is still running.
+func (d *Daemon) ExitState() *os.ProcessState {
+	if d.cmd == nil {
+		return nil
+	}
+	select {
+	case <-d.exited:
+		return d.cmd.ProcessState
+	default:
+		return nil
+	}
+}
+
+// WaitContext waits for the daemon to exit and returns its exit status, or
+// gives up when ctx is done and returns ctx.Err(). Giving up leaves the
+// daemon running; it is still reaped in the background once it exits, so
+// Done and later calls see the exit. A non-zero exit status is not an error.
+// Called by the parent process after a successful Daemonize.
+func (d *Daemon) WaitContext(ctx context.Context) (*os.ProcessState, error) {
+	if d.isDaemon {
+		return nil, ErrNotParentProcess
+	}
+	if d.cmd == nil {
+		return nil, ErrNotStarted
+	}
+	d.reap()
+
+	select {
+	case <-d.exited:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if d.cmd.ProcessState == nil {
+		return nil, fmt.Errorf("wait for daemon: %w", d.waitErr)
+	}
+	return d.cmd.ProcessState, nil
+}
@@ -0,0 +1,71 @@
+package daemonizer_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+func init() {
+	roles["stop-self"] = func(d *godaemonizer.Daemon) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		if err := d.Stop(time.Second); !errors.Is(err, godaemonizer.ErrNotParentProcess) {
+			ready(fmt.Errorf("Stop: %v, want %v", err, godaemonizer.ErrNotParentProcess))
+			return
+		}
+		if err := d.Kill(); !errors.Is(err, godaemonizer.ErrNotParentProcess) {
+			ready(fmt.Errorf("Kill: %v, want %v", err, godaemonizer.ErrNotParentProcess))
+			return
+		}
+		ready(nil)
+	}
+	roles["ignore-term"] = func(d *godaemonizer.Daemon) {
+		signal.Ignore(syscall.SIGTERM)
+		serve(d)
+	}
+}
+
+// TestStopInDaemon checks that Stop and Kill refuse to run in the daemon.
+func TestStopInDaemon(t *testing.T) {
+	startDaemon(t, "stop-self", nil, nil)
+}
+
+// TestKillIgnoresTerm checks that Kill ends a daemon that ignores SIGTERM,
+// and has reaped it when it returns.
+func TestKillIgnoresTerm(t *testing.T) {
+	d := startDaemon(t, "ignore-term", nil, nil)
+	if err := d.Kill(); err != nil {
+		t.Fatalf("Kill: %v", err)
+	}
+	select {
+	case <-d.Done():
+	default:
+		t.Fatal("Kill returned before the daemon was reaped")
+	}
+	if state := d.ExitState(); state == nil || state.Success() {
+		t.Errorf("exit state %v, want killed", state)
+	}
+}
+
+// TestStopTwice checks that a second Stop, which finds the daemon already
+// stopped and reaped, succeeds too.
+func TestStopTwice(t *testing.T) {
+	d := startDaemon(t, "serve", nil, nil)
+	for i := range 2 {
+		if err := d.Stop(time.Second); err != nil {
+			t.Fatalf("Stop %d: %v", i+1, err)
+		}
+	}
+	if d.ExitState() == nil {
+		t.Fatal("no exit state after Stop")
+	}
+}
@@ -0,0 +1,68 @@
+package daemonizer
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// commLen is the longest name PR_SET_NAME keeps, without the NUL.
+const commLen = 15
+
+// titleBuf holds the command line the kernel reports once SetProcessTitle
+// has pointed it there; it must never be collected.
+var titleBuf []byte
+
+// SetProcessTitle changes how the calling process shows up in ps and top,
+// e.g. "myapp: worker". It always sets the short process name that top and
+// "ps -e" show, truncated to 15 bytes. The full command line, as "ps aux"
+// shows it, is replaced too if the process has CAP_SYS_RESOURCE; without it
+// the command line stays as it was and no error is returned.
+//
+// The original arguments are left intact: Go programs keep references into
+// them, e.g. in flag values, so they cannot be overwritten in place.
+func SetProcessTitle(title string) error {
+	name := []byte(title)
+	if len(name) > commLen {
+		name = name[:commLen]
+	}
+	name = append(name, 0)
+	if err := unix.Prctl(unix.PR_SET_NAME, uintptr(unsafe.Pointer(&name[0])), 0, 0, 0); err != nil {
+		return fmt.Errorf("set process name: %w", err)
+	}
+
+	buf := append([]byte(title), 0)
+	start := uintptr(unsafe.Pointer(&buf[0]))
+	end := start + uintptr(len(buf))
+	err := setArgs(start, end)
+	if errors.Is(err, unix.EPERM) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("set command line: %w", err)
+	}
+	titleBuf = buf
+	return nil
+}
+
+// setArgs points the kernel's view of the command line at start..end. The
+// kernel rejects a start after the end at every step, so for a buffer above
+// the current command line the end has to move first.
+func setArgs(start, end uintptr) error {
+	set := func(field int, addr uintptr) error {
+		return unix.Prctl(unix.PR_SET_MM, uintptr(field), addr, 0, 0)
+	}
+	if err := set(unix.PR_SET_MM_ARG_START, start); err != nil {
+		if !errors.Is(err, unix.EINVAL) {
+			return err
+		}
+		// the new buffer lies above the old one
+		if err := set(unix.PR_SET_MM_ARG_END, end); err != nil {
+			return err
+		}
+		return set(unix.PR_SET_MM_ARG_START, start)
+	}
+	return set(unix.PR_SET_MM_ARG_END, end)
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package daemonizer
+
+import "errors"
+
+// SetProcessTitle changes how the calling process shows up in ps. It is
+// only supported on Linux.
+func SetProcessTitle(title string) error {
+	return errors.New("setting the process title is only supported on Linux")
+}
@@ -0,0 +1,303 @@
+package daemonizer_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+// statusFd keeps the second handle on the status fd of the roles that
+// write to it directly from being closed by its finalizer.
+var statusFd *os.File
+
+func init() {
+	roles["progress"] = func(d *godaemonizer.Daemon) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		for i := range 3 {
+			if err := d.ReportProgress(fmt.Sprintf("step %d", i)); err != nil {
+				ready(err)
+				return
+			}
+		}
+		d.SetReadyMessage("ready")
+		ready(nil)
+	}
+	roles["events"] = func(d *godaemonizer.Daemon) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		ready(nil)
+		for i := range 2 {
+			if err := d.SendEvent(i); err != nil {
+				d.SendEvent(err.Error())
+			}
+		}
+	}
+	roles["garbage"] = func(d *godaemonizer.Daemon) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		// a stray write to the status fd, as by code that mistook it for
+		// its own
+		statusFd = os.NewFile(4, "status")
+		fmt.Fprint(statusFd, "not json\n{\"half\": \n\n")
+		d.SetReadyMessage("ready")
+		ready(nil)
+	}
+	roles["version"] = func(d *godaemonizer.Daemon) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		d.SetReadyData(d.ProtocolVersion())
+		ready(nil)
+	}
+	roles["newer-daemon"] = func(d *godaemonizer.Daemon) {
+		if _, err := d.WaitForParent(nil); err != nil {
+			os.Exit(1)
+		}
+		// what a daemon of a later protocol version might send: a message
+		// type and fields this one does not know, then its report
+		statusFd = os.NewFile(4, "status")
+		fmt.Fprintln(statusFd, `{"type":"telemetry","ok":true,"cpu":0.5}`)
+		fmt.Fprintln(statusFd, `{"ok":true,"version":99,"features":["x"]}`)
+		select {}
+	}
+	roles["progress-exit"] = func(d *godaemonizer.Daemon) {
+		var n int
+		ready, err := d.WaitForParent(&n)
+		if err != nil {
+			os.Exit(1)
+		}
+		for i := range n {
+			if err := d.ReportProgress(strconv.Itoa(i)); err != nil {
+				ready(err)
+				return
+			}
+		}
+		// exits as soon as the report is out
+		ready(nil)
+	}
+	roles["echo-exit"] = func(d *godaemonizer.Daemon) {
+		var n int
+		ready, err := d.WaitForParent(&n)
+		if err != nil {
+			os.Exit(1)
+		}
+		// exits as soon as the report is out
+		d.SetReadyData(n)
+		ready(nil)
+	}
+	roles["flood"] = func(d *godaemonizer.Daemon) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		ready(nil)
+		for i := range 100 {
+			d.SendEvent(i)
+		}
+	}
+}
+
+// TestProgressMessages checks that several messages before the report all
+// reach the parent, in order, followed by the report.
+func TestProgressMessages(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+	cfg := &godaemonizer.Config{OnProgress: func(message string) {
+		mu.Lock()
+		got = append(got, message)
+		mu.Unlock()
+	}}
+	d := godaemonizer.New(godaemonizer.WithName("progress"))
+	t.Cleanup(func() { d.Kill() })
+	res, err := d.DaemonizeWithResult(context.Background(), nil, cfg)
+	if err != nil {
+		t.Fatalf("Daemonize: %v", err)
+	}
+
+	mu.Lock()
+	got = append(got, res.Message)
+	mu.Unlock()
+	if want := []string{"step 0", "step 1", "step 2", "ready"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// TestProgressBeforeExit checks, over many runs, that every progress
+// message of a daemon that exits right after its report reaches the parent
+// in order, before Daemonize returns.
+func TestProgressBeforeExit(t *testing.T) {
+	const n = 500
+	for run := range 10 {
+		var got []string
+		cfg := &godaemonizer.Config{OnProgress: func(message string) {
+			got = append(got, message)
+		}}
+		d := godaemonizer.New(godaemonizer.WithName("progress-exit"))
+		if err := d.Daemonize(context.Background(), n, cfg); err != nil {
+			t.Fatalf("run %d: Daemonize: %v", run, err)
+		}
+		// OnProgress runs within Daemonize, so got is complete by now
+		if len(got) != n {
+			t.Fatalf("run %d: got %d progress messages, want %d", run, len(got), n)
+		}
+		for i, message := range got {
+			if message != strconv.Itoa(i) {
+				t.Fatalf("run %d: progress message %d is %q, want %q", run, i, message, strconv.Itoa(i))
+			}
+		}
+		<-d.Done()
+	}
+}
+
+// TestHandshakeStress runs many handshakes, a few at a time, with daemons
+// that exit right after their report, which must still reach the parent
+// whole every time.
+func TestHandshakeStress(t *testing.T) {
+	workers, runs := 4, 25
+	if testing.Short() {
+		runs = 5
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*runs)
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range runs {
+				n := w*runs + i
+				d := godaemonizer.New(godaemonizer.WithName("echo-exit"))
+				var got int
+				if err := d.DaemonizeWithData(context.Background(), n, nil, &got); err != nil {
+					errs <- fmt.Errorf("run %d: Daemonize: %w", n, err)
+					continue
+				}
+				<-d.Done()
+				if got != n {
+					errs <- fmt.Errorf("run %d: daemon reported %d", n, got)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// TestSendEventAfterReady checks that the status pipe stays open after the
+// report, for more than one message.
+func TestSendEventAfterReady(t *testing.T) {
+	d := startDaemon(t, "events", nil, &godaemonizer.Config{KeepOutputOpen: true})
+	var got []string
+	for event := range d.Output() {
+		got = append(got, string(event))
+	}
+	if want := []string{"0", "1"}; !slices.Equal(got, want) {
+		t.Errorf("events %q, want %q", got, want)
+	}
+}
+
+// TestUnreadOutputNoLeak checks that events nobody reads do not keep the
+// parent's reader goroutine alive once the daemon has exited.
+func TestUnreadOutputNoLeak(t *testing.T) {
+	before := runtime.NumGoroutine()
+	d := startDaemon(t, "flood", nil, &godaemonizer.Config{KeepOutputOpen: true})
+	<-d.Done()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines, %d before the daemon started", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestStatusGarbage checks that non-JSON lines on the status pipe are
+// skipped rather than taken for a failure.
+func TestStatusGarbage(t *testing.T) {
+	d := godaemonizer.New(godaemonizer.WithName("garbage"))
+	t.Cleanup(func() { d.Kill() })
+	res, err := d.DaemonizeWithResult(context.Background(), nil, nil)
+	if err != nil {
+		t.Fatalf("Daemonize: %v", err)
+	}
+	if res.Message != "ready" {
+		t.Errorf("ready message %q, want %q", res.Message, "ready")
+	}
+}
+
+// TestOlderParent checks a daemon against parents of other protocol
+// versions: one that sends no version, as before versioning, and a newer
+// one with fields the daemon does not know. The daemon speaks version 1
+// with both.
+func TestOlderParent(t *testing.T) {
+	for _, handoff := range []string{
+		`{"params":{"n":1}}`,
+		`{"params":{"n":1},"version":99,"compression":"zstd"}`,
+	} {
+		paramR, paramW, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		statusR, statusW, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cmd := exec.Command(os.Args[0], "--__daemon__=version")
+		cmd.ExtraFiles = []*os.File{paramR, statusW}
+		err = cmd.Start()
+		paramR.Close()
+		statusW.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintln(paramW, handoff)
+
+		var report struct {
+			OK      bool   `json:"ok"`
+			Error   string `json:"error"`
+			Version int    `json:"version"`
+			Data    int    `json:"data"`
+		}
+		err = json.NewDecoder(statusR).Decode(&report)
+		paramW.Close()
+		statusR.Close()
+		cmd.Wait()
+		if err != nil {
+			t.Fatalf("handoff %s: read report: %v", handoff, err)
+		}
+		if !report.OK || report.Version != 1 || report.Data != 1 {
+			t.Errorf("handoff %s: report %+v, want ok with version 1", handoff, report)
+		}
+	}
+}
+
+// TestNewerDaemon checks that a parent skips a message type it does not
+// know and settles on its own version with a daemon that reports a later
+// one.
+func TestNewerDaemon(t *testing.T) {
+	d := startDaemon(t, "newer-daemon", nil, nil)
+	if v := d.ProtocolVersion(); v != 1 {
+		t.Errorf("ProtocolVersion() = %d, want 1", v)
+	}
+}
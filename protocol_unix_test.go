@@ -0,0 +1,52 @@
+//go:build unix
+
+package daemonizer_test
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+// heldStatus keeps the "hold-status" daemon's copy of the status fd open.
+var heldStatus *os.File
+
+func init() {
+	roles["hold-status"] = func(d *godaemonizer.Daemon) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		// a copy of the status fd, as kept for later heartbeats, outlives
+		// the one the daemon closes after its report
+		fd, err := syscall.Dup(4)
+		if err != nil {
+			ready(err)
+			return
+		}
+		heldStatus = os.NewFile(uintptr(fd), "held_status")
+		ready(nil)
+		select {}
+	}
+}
+
+// TestStatusHeldOpen checks that Daemonize returns once the report is in,
+// even though the daemon keeps the status pipe open.
+func TestStatusHeldOpen(t *testing.T) {
+	d := godaemonizer.New(godaemonizer.WithName("hold-status"))
+	t.Cleanup(func() { d.Kill() })
+	returned := make(chan error, 1)
+	go func() { returned <- d.Daemonize(context.Background(), nil, nil) }()
+	select {
+	case err := <-returned:
+		if err != nil {
+			t.Fatalf("Daemonize: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Daemonize still waiting for the status pipe to close")
+	}
+}
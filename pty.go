@@ -0,0 +1,30 @@
+package daemonizer
+
+import (
+	"fmt"
+	"os"
+)
+
+// PTYMaster returns the master side of the pseudo-terminal that
+// Config.AllocatePTY gave the daemon as its stdin, stdout and stderr, or
+// nil without one. The caller owns it from a successful Daemonize on:
+// read the daemon's output from it, write its input to it, and close it
+// when done. A daemon that fills the terminal's buffer blocks until the
+// master is read, and reads fail once the daemon and everything it started
+// have closed the terminal. Each Restart allocates a new terminal; close
+// the previous master. Called by the parent process.
+func (d *Daemon) PTYMaster() *os.File {
+	return d.pty
+}
+
+// openPTY allocates the pseudo-terminal for cfg.AllocatePTY, or returns
+// nils without it.
+func openPTY(cfg *Config) (master, slave *os.File, err error) {
+	if cfg == nil || !cfg.AllocatePTY {
+		return nil, nil, nil
+	}
+	if master, slave, err = openpt(); err != nil {
+		return nil, nil, fmt.Errorf("allocate pseudo-terminal: %w", err)
+	}
+	return master, slave, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package daemonizer
+
+import (
+	"errors"
+	"os"
+)
+
+func openpt() (master, slave *os.File, err error) {
+	return nil, nil, errors.New("pseudo-terminals are only supported on Linux")
+}
@@ -0,0 +1,37 @@
+package daemonizer_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+func init() {
+	roles["raw-params"] = func(d *godaemonizer.Daemon) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		d.SetReadyData(string(d.RawParams()))
+		ready(nil)
+	}
+}
+
+// TestRawParamsExact checks that a json.RawMessage reaches the daemon
+// unchanged, key order and large integers included, which is what lets an
+// encoding such as protojson ride on the params.
+func TestRawParamsExact(t *testing.T) {
+	raw := `{"z":1,"a":{"id":"12345678901234567890"},"n":12345678901234567890}`
+	d := godaemonizer.New(godaemonizer.WithName("raw-params"))
+	t.Cleanup(func() { d.Kill() })
+	var got string
+	if err := d.DaemonizeWithData(context.Background(), json.RawMessage(raw), nil, &got); err != nil {
+		t.Fatalf("Daemonize: %v", err)
+	}
+	if got != raw {
+		t.Errorf("RawParams = %s, want %s", got, raw)
+	}
+}
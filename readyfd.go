@@ -0,0 +1,80 @@
+package daemonizer
+
+import (
+	"os"
+	"os/exec"
+)
+
+// SignalReadyFd signals readiness for Config.ReadinessViaFd by writing a
+// single byte to the readiness fd, and closes it. It may be called from
+// anywhere in the daemon, before or after ready, which must still be called
+// and is what reports a failure. Later calls do nothing. It returns
+// ErrNoReadyFd if the parent did not ask for a readiness fd. Called by the
+// daemon process after WaitForParent.
+func (d *Daemon) SignalReadyFd() error {
+	if d.readyFd == nil {
+		return ErrNoReadyFd
+	}
+	var err error
+	d.readyOnce.Do(func() {
+		_, err = d.readyFd.Write([]byte{'\n'})
+		d.readyFd.Close()
+	})
+	return err
+}
+
+// readyPipe is the parent's side of Config.ReadinessViaFd: a pipe whose
+// write end the daemon inherits.
+type readyPipe struct {
+	r, w *os.File
+}
+
+// openReadyPipe opens the readiness pipe, or returns nil without
+// cfg.ReadinessViaFd.
+func openReadyPipe(cfg *Config) (*readyPipe, error) {
+	if cfg == nil || !cfg.ReadinessViaFd {
+		return nil, nil
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, pipeError("readiness pipe", err)
+	}
+	return &readyPipe{r: r, w: w}, nil
+}
+
+// attach passes the write end after all other inherited files.
+func (p *readyPipe) attach(cmd *exec.Cmd) {
+	if p == nil {
+		return
+	}
+	cmd.ExtraFiles = append(cmd.ExtraFiles, p.w)
+}
+
+// wait closes the parent's write end, so that the daemon's close or exit
+// reads as EOF, and returns a channel that gets nil once the byte arrives,
+// or the read error without it. It returns nil, which blocks forever,
+// without a readiness pipe. Call it once the daemon has started.
+func (p *readyPipe) wait() <-chan error {
+	if p == nil {
+		return nil
+	}
+	p.w.Close()
+	p.w = nil
+	c := make(chan error, 1)
+	go func() {
+		var b [1]byte
+		_, err := p.r.Read(b[:])
+		c <- err
+	}()
+	return c
+}
+
+// release closes the parent's ends of the pipe, which also ends a wait in
+// progress.
+func (p *readyPipe) release() {
+	if p == nil {
+		return
+	}
+	closeIfOpen(p.r)
+	closeIfOpen(p.w)
+}
@@ -0,0 +1,124 @@
+package daemonizer_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+func init() {
+	// signals before reporting, and records what the handshake looked like
+	// from its side afterwards
+	roles["ready-byte-first"] = func(d *godaemonizer.Daemon) {
+		var p struct{ Out string }
+		ready, err := d.WaitForParent(&p)
+		if err != nil {
+			os.Exit(1)
+		}
+		d.SignalReadyFd()
+		time.Sleep(200 * time.Millisecond)
+		cancelled := false
+		select {
+		case <-d.Cancelled():
+			cancelled = true
+		default:
+		}
+		progress := d.ReportProgress("still starting")
+		ready(nil)
+		os.WriteFile(p.Out, []byte(fmt.Sprintf("cancelled=%v progress=%v", cancelled, progress)), 0o644)
+	}
+	roles["ready-report-first"] = func(d *godaemonizer.Daemon) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		d.SetReadyMessage("hello")
+		ready(nil)
+		time.Sleep(50 * time.Millisecond)
+		d.SignalReadyFd()
+		select {}
+	}
+	roles["ready-fail-then-byte"] = func(d *godaemonizer.Daemon) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		ready(errors.New("boom"))
+		time.Sleep(50 * time.Millisecond)
+		d.SignalReadyFd()
+		time.Sleep(time.Second)
+	}
+	roles["ready-no-byte"] = func(d *godaemonizer.Daemon) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		ready(nil)
+	}
+}
+
+var readyFdConfig = &godaemonizer.Config{ReadinessViaFd: true}
+
+func TestReadinessFdBeforeReport(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	d := startDaemon(t, "ready-byte-first", map[string]string{"Out": out}, readyFdConfig)
+	<-d.Done()
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "cancelled=false progress=<nil>"; got != want {
+		t.Fatalf("daemon saw %q, want %q", got, want)
+	}
+}
+
+func TestReadinessFdKeepsEarlyReport(t *testing.T) {
+	d := godaemonizer.New(godaemonizer.WithName("ready-report-first"))
+	res, err := d.DaemonizeWithResult(context.Background(), nil, readyFdConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Kill()
+	if res.Message != "hello" {
+		t.Fatalf("message %q, want the report's %q", res.Message, "hello")
+	}
+	if d.ProtocolVersion() == 0 {
+		t.Fatal("protocol version of the report lost")
+	}
+}
+
+func TestReadinessFdKeepsEarlyFailure(t *testing.T) {
+	d := godaemonizer.New(godaemonizer.WithName("ready-fail-then-byte"))
+	err := d.Daemonize(context.Background(), nil, readyFdConfig)
+	var de *godaemonizer.DaemonError
+	if !errors.As(err, &de) || de.Message != "boom" {
+		t.Fatalf("Daemonize = %v, want the daemon's failure", err)
+	}
+}
+
+func TestReadinessFdClosedWithoutByte(t *testing.T) {
+	d := godaemonizer.New(godaemonizer.WithName("ready-no-byte"))
+	err := d.Daemonize(context.Background(), nil, readyFdConfig)
+	if !errors.Is(err, godaemonizer.ErrDaemonFailed) {
+		t.Fatalf("Daemonize = %v, want ErrDaemonFailed", err)
+	}
+}
+
+func TestReadinessFdExclusive(t *testing.T) {
+	cfg := &godaemonizer.Config{ReadinessViaFd: true, Control: true}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("ReadinessViaFd with Control validated")
+	}
+}
+
+func TestSignalReadyFdWithoutFd(t *testing.T) {
+	if err := godaemonizer.New().SignalReadyFd(); !errors.Is(err, godaemonizer.ErrNoReadyFd) {
+		t.Fatalf("SignalReadyFd = %v, want ErrNoReadyFd", err)
+	}
+}
@@ -0,0 +1,70 @@
+package daemonizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// ReExec hands the daemon over to a new binary, for zero-downtime
+// upgrades. It starts binary as a daemon with the same arguments and name,
+// passing state as its params and the daemon's listeners and extra files
+// as its Config.Listeners and Config.ExtraFiles, and waits for it to report
+// readiness, like Daemonize. The successor also takes over the PID file and
+// control socket; its stdout and stderr are the daemon's own.
+//
+// Once the successor is ready, ReExec returns nil and triggers the daemon's
+// shutdown as SIGTERM would, so its OnShutdown hooks can drain open
+// connections before it exits. If the successor fails to start, the daemon
+// carries on as before and ReExec returns the error.
+// Called by the daemon process after WaitForParent.
+func (d *Daemon) ReExec(ctx context.Context, binary string, state map[string]any) error {
+	if !d.isDaemon {
+		return errors.New("not a daemon process")
+	}
+	listeners, err := d.Listeners()
+	if err != nil {
+		return err
+	}
+
+	cfg := &Config{
+		Env:        d.launchEnv,
+		Stdout:     os.Stdout,
+		Stderr:     os.Stderr,
+		PIDFile:    d.pidFile,
+		Listeners:  listeners,
+		ExtraFiles: d.files,
+	}
+	if d.types != defaultStatusTypes {
+		types := d.types
+		cfg.StatusTypes = &types
+	}
+
+	// the successor cannot listen on the control socket while the daemon
+	// does, so the daemon stops serving it for the handover
+	control := d.controlPath
+	if control != "" {
+		cfg.ControlSocket = control
+		d.closeControl()
+	}
+
+	next := d.sibling()
+	next.binary = binary
+	if _, err := next.launch(ctx, state, cfg); err != nil {
+		if control != "" {
+			if lerr := d.listenControl(control); lerr != nil {
+				d.logger.Error("listen on control socket again", "error", lerr)
+			}
+		}
+		return fmt.Errorf("start successor: %w", err)
+	}
+	d.logger.Info("handed over to successor", "pid", next.PID(), "binary", binary)
+
+	// the same path as a real SIGTERM, hooks and all
+	if p, err := os.FindProcess(os.Getpid()); err == nil {
+		p.Signal(syscall.SIGTERM)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package daemonizer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// launchSpec records the params and Config of the last successful
+// Daemonize, for Restart.
+type launchSpec struct {
+	params json.RawMessage
+	cfg    *Config
+}
+
+// Restart stops the daemon like Stop(grace) and starts a fresh one with the
+// same params and Config as the last successful Daemonize; the params are
+// resent exactly as they were serialized then. A daemon that had to be
+// killed does not prevent the restart, but one that could not be stopped
+// at all does. With Config.TransferState the daemon's state is dumped
+// first and handed to the new one. It returns ErrNoRestartSpec if this
+// Daemon did not start the daemon itself, and ErrNotRestartable, before
+// stopping anything, if the Config held resources the first daemon used
+// up. Called by the parent process.
+func (d *Daemon) Restart(ctx context.Context, grace time.Duration) error {
+	if d.isClosed() {
+		return ErrClosed
+	}
+	if d.cmd == nil {
+		return ErrNotStarted
+	}
+	spec := d.launched
+	if spec == nil {
+		return ErrNoRestartSpec
+	}
+	if err := spec.cfg.reusable(); err != nil {
+		return err
+	}
+
+	state := d.dumpState(spec.cfg)
+	if err := d.Stop(grace); err != nil && !errors.Is(err, ErrStopKilled) {
+		return fmt.Errorf("stop daemon: %w", err)
+	}
+	d.reset()
+	d.state = state
+	return d.Daemonize(ctx, spec.params, spec.cfg)
+}
+
+// reusable reports why c cannot start a second daemon: the first one reads
+// a ParamStream to its end, and CloseStdio closes Stdout and Stderr in the
+// parent once the first one has started.
+func (c *Config) reusable() error {
+	switch {
+	case c == nil:
+		return nil
+	case c.ParamStream != nil:
+		return fmt.Errorf("%w: the first daemon consumed Config.ParamStream", ErrNotRestartable)
+	case c.CloseStdio && (c.Stdout != nil && c.Stdout != os.Stdout || c.Stderr != nil && c.Stderr != os.Stderr):
+		return fmt.Errorf("%w: Config.CloseStdio closed Stdout and Stderr", ErrNotRestartable)
+	}
+	return nil
+}
+
+// reset forgets the stopped daemon so that Daemonize can start another.
+func (d *Daemon) reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.started = false
+	d.cmd = nil
+	d.proc = nil
+	d.waitOnce = sync.Once{}
+	d.exited = nil
+	d.waitErr = nil
+	d.channel = nil
+	d.launched = nil
+	if d.client != nil {
+		d.client.Close()
+		d.client = nil
+	}
+	d.controlSocket = ""
+	d.pty = nil
+}
+
+// RestartPreservingFds performs a graceful restart: it starts a new daemon
+// with params and cfg, passing fds (e.g. the listening socket the current
+// daemon serves) as its Config.ExtraFiles, and waits for it to become
+// ready. Only then is the current daemon sent SIGTERM, its cue to drain
+// open connections and exit, so no connection is refused in between. If
+// the new daemon fails to start, the current one is left untouched.
+//
+// It returns the Daemon that manages the new process; d keeps managing the
+// old one, e.g. for Done or Stop. Called by the parent process after a
+// successful Daemonize.
+func (d *Daemon) RestartPreservingFds(ctx context.Context, params any, cfg *Config, fds []*os.File) (*Daemon, error) {
+	if d.isClosed() {
+		return nil, ErrClosed
+	}
+	if d.cmd == nil {
+		return nil, ErrNotStarted
+	}
+
+	var c Config
+	if cfg != nil {
+		c = *cfg
+	}
+	c.ExtraFiles = fds
+
+	next := d.sibling()
+	if err := next.Daemonize(ctx, params, &c); err != nil {
+		return nil, fmt.Errorf("start new daemon: %w", err)
+	}
+
+	d.reap()
+	if err := d.proc.Signal(syscall.SIGTERM); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return next, fmt.Errorf("signal old daemon: %w", err)
+	}
+	return next, nil
+}
+
+// sibling returns a fresh parent-side Daemon with d's options, for starting
+// another daemon alongside or instead of d's.
+func (d *Daemon) sibling() *Daemon {
+	return &Daemon{
+		args:         d.args,
+		logger:       d.untaggedLogger(),
+		marker:       d.marker,
+		name:         d.name,
+		preserveArgs: d.preserveArgs,
+		binary:       d.binary,
+		blobs:        d.blobs,
+
+		shutdownTimeout: d.shutdownTimeout,
+		paramsTimeout:   d.paramsTimeout,
+	}
+}
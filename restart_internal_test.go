@@ -0,0 +1,12 @@
+package daemonizer
+
+import "testing"
+
+// TestSiblingKeepsBinary checks that the Daemon managing a successor that
+// ReExec started restarts the successor's binary, not the original one.
+func TestSiblingKeepsBinary(t *testing.T) {
+	d := &Daemon{binary: "/usr/local/bin/app-v2"}
+	if got := d.sibling().binary; got != d.binary {
+		t.Fatalf("sibling binary = %q, want %q", got, d.binary)
+	}
+}
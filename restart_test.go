@@ -0,0 +1,83 @@
+package daemonizer_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+func TestRestartExitedDaemon(t *testing.T) {
+	d := startDaemon(t, "exit", nil, nil)
+	for i := range 5 {
+		pid := d.PID()
+		waitExited(t, pid)
+		if err := d.Restart(context.Background(), time.Second); err != nil {
+			t.Fatalf("restart %d: %v", i, err)
+		}
+		if d.PID() == pid {
+			t.Fatalf("restart %d kept pid %d", i, pid)
+		}
+	}
+	<-d.Done()
+}
+
+// TestRestartRunningDaemon checks that Restart stops a running daemon and
+// starts another in its place, and that it needs a daemon to restart.
+func TestRestartRunningDaemon(t *testing.T) {
+	if err := godaemonizer.New().Restart(context.Background(), time.Second); !errors.Is(err, godaemonizer.ErrNotStarted) {
+		t.Fatalf("Restart before Daemonize: %v, want %v", err, godaemonizer.ErrNotStarted)
+	}
+
+	d := startDaemon(t, "serve", nil, nil)
+	pid := d.PID()
+	if err := d.Restart(context.Background(), time.Second); err != nil {
+		t.Fatalf("Restart: %v", err)
+	}
+	waitExited(t, pid)
+	if d.PID() == pid {
+		t.Fatalf("Restart kept pid %d", pid)
+	}
+	select {
+	case <-d.Done():
+		t.Fatal("the new daemon is not running")
+	default:
+	}
+}
+
+func TestStopExitedDaemonReaps(t *testing.T) {
+	d := startDaemon(t, "exit", nil, nil)
+	waitExited(t, d.PID())
+	if err := d.Stop(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-d.Done():
+	default:
+		t.Fatal("Stop returned before the daemon was reaped")
+	}
+	if d.ExitState() == nil {
+		t.Fatal("no exit state after Stop")
+	}
+}
+
+func TestRestartOneShotConfig(t *testing.T) {
+	cfg := &godaemonizer.Config{ParamStream: strings.NewReader("stream")}
+	d := startDaemon(t, "serve", nil, cfg)
+	if err := d.Restart(context.Background(), time.Second); !errors.Is(err, godaemonizer.ErrNotRestartable) {
+		t.Fatalf("Restart: %v, want %v", err, godaemonizer.ErrNotRestartable)
+	}
+	select {
+	case <-d.Done():
+		t.Fatal("Restart stopped the daemon it could not replace")
+	default:
+	}
+
+	_, err := godaemonizer.NewSupervisor(godaemonizer.DaemonSpec{Name: "serve", Config: cfg})
+	if !errors.Is(err, godaemonizer.ErrNotRestartable) {
+		t.Fatalf("NewSupervisor: %v, want %v", err, godaemonizer.ErrNotRestartable)
+	}
+}
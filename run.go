@@ -0,0 +1,46 @@
+package daemonizer
+
+import "context"
+
+// Run is the usual main of a daemonizing program in one call, for programs
+// that need no more control than it offers; the lower-level API remains
+// for everything else.
+//
+// In the parent, Run daemonizes with params and cfg and, once the daemon is
+// ready, calls parent, if not nil, e.g. to print where the daemon listens.
+// It returns the first error of the two.
+//
+// In the daemon, Run waits for the params and calls daemon with them to set
+// the daemon up, e.g. to start its listeners in goroutines; daemon must
+// return once setup is done. Its result is reported to the parent as the
+// readiness, and a failure is returned. After a successful setup Run never
+// returns: on SIGTERM or SIGINT it runs the OnShutdown hooks, registered by
+// daemon or earlier, and exits.
+func (d *Daemon) Run(ctx context.Context, params any, cfg *Config, parent func() error, daemon func(params Params) error) error {
+	if !d.isDaemon {
+		if err := d.Daemonize(ctx, params, cfg); err != nil {
+			return err
+		}
+		if parent != nil {
+			return parent()
+		}
+		return nil
+	}
+
+	ready, err := d.WaitForParent(nil)
+	if err != nil {
+		return err
+	}
+	p, err := d.ParamsMap()
+	if err == nil {
+		err = daemon(p)
+	}
+	ready(err)
+	if err != nil {
+		return err
+	}
+
+	// make sure SIGTERM takes the graceful path even without hooks
+	d.OnShutdown(func(context.Context) error { return nil })
+	select {}
+}
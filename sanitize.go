@@ -0,0 +1,106 @@
+package daemonizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// SanitizeParams returns a copy of params in which values that JSON would
+// mangle are replaced by a readable form, and values it cannot encode at
+// all, such as functions and channels, are dropped. Nested maps and slices
+// are cleaned too. The second result lists the paths of the keys that were
+// changed, e.g. "timeout" or "limits.retry" or "hosts[1]", in a stable
+// order, so callers can see what would not survive the trip to the daemon.
+//
+// A time.Duration becomes its String form ("1m30s"), which Params.Duration
+// reads back, instead of a count of nanoseconds; an os.FileMode becomes an
+// octal string ("0644"). Everything else is kept as is.
+func SanitizeParams(params map[string]any) (map[string]any, []string) {
+	var changed []string
+	return sanitizeMap(params, "", &changed), changed
+}
+
+// sanitizeParams applies Config.SanitizeParams to map params, logging what
+// was changed.
+func (d *Daemon) sanitizeParams(params any, cfg *Config) any {
+	if cfg == nil || !cfg.SanitizeParams {
+		return params
+	}
+	var m map[string]any
+	switch p := params.(type) {
+	case map[string]any:
+		m = p
+	case Params:
+		m = p
+	default:
+		return params
+	}
+	clean, changed := SanitizeParams(m)
+	if len(changed) > 0 {
+		d.logger.Warn("params sanitized for JSON", "keys", changed)
+	}
+	return clean
+}
+
+func sanitizeMap(m map[string]any, prefix string, changed *[]string) map[string]any {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]any, len(m))
+	for _, key := range slices.Sorted(maps.Keys(m)) {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if v, keep := sanitizeValue(m[key], path, changed); keep {
+			out[key] = v
+		}
+	}
+	return out
+}
+
+// sanitizeValue returns the cleaned v and whether to keep it at all.
+func sanitizeValue(v any, path string, changed *[]string) (any, bool) {
+	switch v := v.(type) {
+	case nil:
+		return nil, true
+	case time.Duration:
+		*changed = append(*changed, path)
+		return v.String(), true
+	case os.FileMode:
+		*changed = append(*changed, path)
+		return fmt.Sprintf("%#o", uint32(v)), true
+	case map[string]any:
+		return sanitizeMap(v, path, changed), true
+	case Params:
+		return sanitizeMap(v, path, changed), true
+	case []any:
+		out := make([]any, 0, len(v))
+		for i, e := range v {
+			// a dropped element becomes null, keeping the indexes of the rest
+			clean, keep := sanitizeValue(e, path+"["+strconv.Itoa(i)+"]", changed)
+			if !keep {
+				clean = nil
+			}
+			out = append(out, clean)
+		}
+		return out, true
+	}
+
+	switch reflect.TypeOf(v).Kind() {
+	case reflect.Func, reflect.Chan, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+		*changed = append(*changed, path)
+		return nil, false
+	}
+	if _, err := json.Marshal(v); err != nil {
+		*changed = append(*changed, path)
+		return nil, false
+	}
+	return v, true
+}
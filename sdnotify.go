@@ -0,0 +1,69 @@
+package daemonizer
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// notifySocketEnv names the service manager's notification socket; systemd
+// sets it for Type=notify services.
+const notifySocketEnv = "NOTIFY_SOCKET"
+
+// sdNotify sends state, e.g. "READY=1", to the service manager, as
+// sd_notify(3) does. Without NOTIFY_SOCKET it does nothing.
+func sdNotify(state string) error {
+	addr := os.Getenv(notifySocketEnv)
+	if addr == "" {
+		return nil
+	}
+	// a leading "@" names an abstract socket, which net maps itself
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("notify service manager: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("notify service manager: %w", err)
+	}
+	return nil
+}
+
+// watchdogInterval returns how often to ping the service manager's
+// watchdog, half the timeout in WATCHDOG_USEC, or 0 if it is not enabled.
+// WATCHDOG_PID is not checked: it names the parent, which handed the
+// service over to the daemon with MAINPID.
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+// notifyReady tells the service manager that the daemon, which becomes the
+// service's main process, is ready, and starts pinging the watchdog if it
+// is enabled. Failures are logged; the daemon runs on regardless.
+func (d *Daemon) notifyReady() {
+	if os.Getenv(notifySocketEnv) == "" {
+		return
+	}
+	state := fmt.Sprintf("READY=1\nMAINPID=%d", os.Getpid())
+	if err := sdNotify(state); err != nil {
+		d.logger.Error("systemd readiness notification", "error", err)
+		return
+	}
+	interval := watchdogInterval()
+	if interval == 0 {
+		return
+	}
+	go func() {
+		for range time.Tick(interval) {
+			if err := sdNotify("WATCHDOG=1"); err != nil {
+				d.logger.Error("systemd watchdog ping", "error", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,74 @@
+package daemonizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// redacted replaces the values of sensitive keys in anything the library
+// logs or reports.
+const redacted = "[REDACTED]"
+
+// encodeSecrets serializes cfg.Secrets for the handoff, apart from the
+// params.
+func encodeSecrets(cfg *Config) (json.RawMessage, error) {
+	if cfg == nil || cfg.Secrets == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(cfg.Secrets)
+	if err != nil {
+		return nil, fmt.Errorf("encode secrets: %w", err)
+	}
+	return data, nil
+}
+
+// redactParams returns payload with the values of cfg.SensitiveKeys
+// replaced, at any depth. Payloads that are not JSON objects or arrays are
+// returned unchanged.
+func redactParams(payload []byte, cfg *Config) []byte {
+	if cfg == nil || len(cfg.SensitiveKeys) == 0 {
+		return payload
+	}
+
+	v, err := decodeExact(payload)
+	if err != nil {
+		return payload
+	}
+	out, err := json.Marshal(redactValue(v, cfg.SensitiveKeys))
+	if err != nil {
+		return payload
+	}
+	return out
+}
+
+func redactValue(v any, keys []string) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, elem := range v {
+			if slices.Contains(keys, k) {
+				v[k] = redacted
+			} else {
+				v[k] = redactValue(elem, keys)
+			}
+		}
+	case []any:
+		for i, elem := range v {
+			v[i] = redactValue(elem, keys)
+		}
+	}
+	return v
+}
+
+// Secrets decodes the secrets the parent passed in Config.Secrets into dest
+// (a pointer). It returns ErrNoSecrets if the parent sent none.
+// Called by the daemon process after WaitForParent.
+func (d *Daemon) Secrets(dest any) error {
+	if d.secrets == nil {
+		return ErrNoSecrets
+	}
+	if err := json.Unmarshal(d.secrets, dest); err != nil {
+		return fmt.Errorf("decode secrets: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+
+package daemonizer
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var errNoServices = errors.New("Windows services are only supported on Windows")
+
+// IsService reports whether the process was started by the Windows Service
+// Control Manager, which is never the case outside Windows.
+func IsService() bool {
+	return false
+}
+
+// RunService is only supported on Windows; elsewhere, run the program as a
+// daemon with Daemonize.
+func (d *Daemon) RunService(name string, run func(ctx context.Context) error) error {
+	return errNoServices
+}
+
+// StopService is only supported on Windows.
+func StopService(name string, timeout time.Duration) error {
+	return errNoServices
+}
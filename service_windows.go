@@ -0,0 +1,120 @@
+package daemonizer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// IsService reports whether the process was started by the Service Control
+// Manager, i.e. whether it should call RunService rather than act as a
+// parent or a daemon.
+func IsService() bool {
+	ok, err := svc.IsWindowsService()
+	return err == nil && ok
+}
+
+// RunService runs the process as the Windows service name, which the
+// Service Control Manager starts rather than a parent. run is the service's
+// body: it should serve until ctx is done. A stop or shutdown request from
+// the SCM cancels ctx, waits up to the shutdown timeout for run to return,
+// and runs the OnShutdown hooks, like SIGTERM does for a daemon. A non-nil
+// error from run is reported to the SCM as a service-specific exit code of
+// 1. RunService returns once the service has stopped; the program should
+// then exit. Called by the service process.
+func (d *Daemon) RunService(name string, run func(ctx context.Context) error) error {
+	return svc.Run(name, &serviceHandler{d: d, run: run})
+}
+
+// serviceHandler maps SCM control requests onto the daemon's lifecycle.
+type serviceHandler struct {
+	d   *Daemon
+	run func(ctx context.Context) error
+}
+
+func (h *serviceHandler) Execute(args []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- h.run(ctx)
+	}()
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	var err error
+loop:
+	for {
+		select {
+		case err = <-done:
+			break loop
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				changes <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				h.d.logger.Info("shutting down", "request", serviceRequest(req.Cmd))
+				changes <- svc.Status{State: svc.StopPending}
+				cancel()
+				select {
+				case err = <-done:
+				case <-time.After(h.d.shutdownTimeout):
+					h.d.logger.Error("service did not stop within the shutdown timeout")
+				}
+				break loop
+			}
+		}
+	}
+
+	changes <- svc.Status{State: svc.StopPending}
+	h.d.runShutdownHooks()
+	if err != nil {
+		h.d.logger.Error("service failed", "error", err)
+		return true, 1
+	}
+	return false, 0
+}
+
+func serviceRequest(cmd svc.Cmd) string {
+	if cmd == svc.Shutdown {
+		return "shutdown"
+	}
+	return "stop"
+}
+
+// StopService asks the Service Control Manager to stop the service name
+// and waits up to timeout for it to reach the stopped state. It is the
+// counterpart of Stop for a daemon run with RunService, and needs the right
+// to control the service. Called by any process.
+func StopService(name string, timeout time.Duration) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("open service %s: %w", name, err)
+	}
+	defer s.Close()
+
+	st, err := s.Control(svc.Stop)
+	if err != nil {
+		return fmt.Errorf("stop service %s: %w", name, err)
+	}
+	deadline := time.Now().Add(timeout)
+	for st.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("service %s did not stop within %v", name, timeout)
+		}
+		time.Sleep(goneInterval)
+		if st, err = s.Query(); err != nil {
+			return fmt.Errorf("query service %s: %w", name, err)
+		}
+	}
+	return nil
+}
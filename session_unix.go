@@ -0,0 +1,17 @@
+//go:build unix
+
+package daemonizer
+
+import "syscall"
+
+// canReexec reports why the daemon cannot be started on this platform.
+func canReexec() error {
+	return nil
+}
+
+// newSession makes the daemon the leader of a session of its own, so that
+// it has no controlling terminal and neither the terminal's hangup nor
+// signals sent to the parent's process group reach it.
+func newSession(attr *syscall.SysProcAttr) {
+	attr.Setsid = true
+}
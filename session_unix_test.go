@@ -0,0 +1,61 @@
+//go:build unix
+
+package daemonizer_test
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+	"golang.org/x/sys/unix"
+)
+
+// sessionReport is what the "session" daemon reports about itself.
+type sessionReport struct {
+	PID, SID, PGID int
+}
+
+func init() {
+	roles["session"] = func(d *godaemonizer.Daemon) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		sid, err := unix.Getsid(0)
+		if err != nil {
+			ready(err)
+			return
+		}
+		d.SetReadyData(sessionReport{PID: os.Getpid(), SID: sid, PGID: syscall.Getpgrp()})
+		ready(nil)
+		select {}
+	}
+}
+
+// TestDaemonSession checks that the daemon leads a session and process
+// group of its own, so that neither the parent's terminal hanging up nor a
+// signal to the parent's process group reaches it, and that it keeps
+// running once the parent has let go of it.
+func TestDaemonSession(t *testing.T) {
+	d := godaemonizer.New(godaemonizer.WithName("session"))
+	t.Cleanup(func() { syscall.Kill(d.PID(), syscall.SIGKILL) })
+	var got sessionReport
+	if err := d.DaemonizeWithData(context.Background(), nil, nil, &got); err != nil {
+		t.Fatalf("Daemonize: %v", err)
+	}
+	if got.PID != d.PID() || got.SID != got.PID || got.PGID != got.PID {
+		t.Errorf("daemon reported %+v, want pid %d leading its own session and process group", got, d.PID())
+	}
+	if sid, _ := unix.Getsid(0); got.SID == sid {
+		t.Errorf("daemon in the parent's session %d", sid)
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := syscall.Kill(got.PID, 0); err != nil {
+		t.Errorf("daemon not running once the parent let go: %v", err)
+	}
+}
@@ -0,0 +1,25 @@
+//go:build windows
+
+package daemonizer
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// errNoReexec is why Daemonize fails on Windows: os/exec passes no files
+// beyond the standard streams there, so the daemon could not inherit the
+// handshake pipes on fds 3 and 4.
+var errNoReexec = fmt.Errorf("%w: Daemonize cannot pass its handshake pipes on Windows; run the program as a service with RunService", errors.ErrUnsupported)
+
+// canReexec reports why the daemon cannot be started on this platform.
+func canReexec() error {
+	return errNoReexec
+}
+
+// newSession puts the daemon in a process group of its own, so that a
+// Ctrl+C or Ctrl+Break sent to the parent's console does not reach it.
+func newSession(attr *syscall.SysProcAttr) {
+	attr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
+}
@@ -0,0 +1,20 @@
+//go:build windows
+
+package daemonizer_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+// TestDaemonizeUnsupported checks that Daemonize fails early on Windows,
+// where the daemon could not inherit the handshake pipes.
+func TestDaemonizeUnsupported(t *testing.T) {
+	err := godaemonizer.New().Daemonize(context.Background(), nil, nil)
+	if !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("Daemonize: %v, want %v", err, errors.ErrUnsupported)
+	}
+}
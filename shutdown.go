@@ -0,0 +1,74 @@
+package daemonizer
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// defaultShutdownTimeout bounds the OnShutdown hooks unless
+// WithShutdownTimeout says otherwise.
+const defaultShutdownTimeout = 10 * time.Second
+
+// OnShutdown registers fn to run when the daemon receives SIGTERM or
+// SIGINT. Hooks run one at a time, most recently registered first, with a
+// context that expires after the shutdown timeout (see WithShutdownTimeout);
+// errors are logged. Once all hooks have returned the daemon removes its
+// PID file, control socket and status file, acknowledges the shutdown to the parent if
+// the status pipe is still open, and exits.
+// Called by the daemon process.
+func (d *Daemon) OnShutdown(fn func(ctx context.Context) error) {
+	d.shutdownMu.Lock()
+	defer d.shutdownMu.Unlock()
+
+	d.shutdownHooks = append(d.shutdownHooks, fn)
+	if len(d.shutdownHooks) == 1 {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGTERM, os.Interrupt)
+		go d.awaitShutdown(sig)
+	}
+}
+
+// awaitShutdown runs the shutdown hooks on the first signal, then exits.
+func (d *Daemon) awaitShutdown(sig <-chan os.Signal) {
+	s := <-sig
+	d.logger.Info("shutting down", "signal", s.String())
+	d.runShutdownHooks()
+
+	d.outMu.Lock()
+	if d.out != nil && d.outReady {
+		d.out.Encode(status{Type: d.types.Shutdown, OK: true})
+	}
+	d.outMu.Unlock()
+	os.Exit(0)
+}
+
+// runShutdownHooks runs the OnShutdown hooks within the shutdown timeout
+// and removes what the daemon leaves behind: PID file, control socket and
+// status file.
+func (d *Daemon) runShutdownHooks() {
+	if d.notify {
+		if err := sdNotify("STOPPING=1"); err != nil {
+			d.logger.Error("systemd stopping notification", "error", err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d.shutdownTimeout)
+	defer cancel()
+
+	d.shutdownMu.Lock()
+	hooks := d.shutdownHooks
+	d.shutdownMu.Unlock()
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i](ctx); err != nil {
+			d.logger.Error("shutdown hook failed", "error", err)
+		}
+	}
+	if err := d.RemovePIDFile(); err != nil {
+		d.logger.Error("remove PID file", "error", err)
+	}
+	d.closeControl()
+	d.removeStatusFile()
+}
@@ -0,0 +1,78 @@
+//go:build unix
+
+package daemonizer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// socketPipes creates a socket pair for TransportSocketpair. Each end is
+// returned twice, as separate fds, so the handshake code can close its read
+// and write sides independently, as with two pipes.
+func socketPipes() (paramR, paramW, statusR, statusW *os.File, err error) {
+	// like the net package, hold ForkLock so no child inherits the fds
+	// before they are marked close-on-exec
+	syscall.ForkLock.RLock()
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM, 0)
+	if err == nil {
+		unix.CloseOnExec(fds[0])
+		unix.CloseOnExec(fds[1])
+	}
+	syscall.ForkLock.RUnlock()
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("create socket pair: %w", err)
+	}
+
+	// the parent's end is non-blocking so that write deadlines work
+	if err := unix.SetNonblock(fds[0], true); err != nil {
+		unix.Close(fds[0])
+		unix.Close(fds[1])
+		return nil, nil, nil, nil, err
+	}
+
+	parentR, err := unix.FcntlInt(uintptr(fds[0]), unix.F_DUPFD_CLOEXEC, 0)
+	if err != nil {
+		unix.Close(fds[0])
+		unix.Close(fds[1])
+		return nil, nil, nil, nil, err
+	}
+	childW, err := unix.FcntlInt(uintptr(fds[1]), unix.F_DUPFD_CLOEXEC, 0)
+	if err != nil {
+		unix.Close(fds[0])
+		unix.Close(fds[1])
+		unix.Close(parentR)
+		return nil, nil, nil, nil, err
+	}
+
+	return os.NewFile(uintptr(fds[1]), "param_socket"),
+		os.NewFile(uintptr(fds[0]), "param_socket"),
+		os.NewFile(uintptr(parentR), "status_socket"),
+		os.NewFile(uintptr(childW), "status_socket"),
+		nil
+}
+
+// closeWrite closes f, first shutting down the sending direction if f is a
+// socket: other fds may share the socket, so closing f alone would not
+// signal EOF to the peer. Pipes are just closed.
+func closeWrite(f *os.File) {
+	if f == nil {
+		return
+	}
+	if rc, err := f.SyscallConn(); err == nil {
+		rc.Control(func(fd uintptr) {
+			unix.Shutdown(int(fd), unix.SHUT_WR)
+		})
+	}
+	f.Close()
+}
+
+// brokenPipe reports whether a write failed because nobody reads the other
+// end anymore.
+func brokenPipe(err error) bool {
+	return errors.Is(err, syscall.EPIPE)
+}
@@ -0,0 +1,22 @@
+package daemonizer
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+func socketPipes() (paramR, paramW, statusR, statusW *os.File, err error) {
+	return nil, nil, nil, nil, errors.New("socket pairs are not supported on Windows")
+}
+
+// errNoData is ERROR_NO_DATA, a write to a pipe whose read end is closed.
+const errNoData = syscall.Errno(232)
+
+func brokenPipe(err error) bool {
+	return errors.Is(err, syscall.ERROR_BROKEN_PIPE) || errors.Is(err, errNoData)
+}
+
+func closeWrite(f *os.File) {
+	closeIfOpen(f)
+}
@@ -0,0 +1,47 @@
+package daemonizer_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+// failSpawns returns a PreStart hook that makes the first n starts fail
+// with err, and a count of the starts attempted.
+func failSpawns(n int, err error) (func(*exec.Cmd) error, *int) {
+	attempts := 0
+	return func(cmd *exec.Cmd) error {
+		attempts++
+		if attempts <= n {
+			cmd.Err = os.NewSyscallError("fork/exec", err)
+		}
+		return nil
+	}, &attempts
+}
+
+// TestSpawnRetries checks that a transient fork failure is retried.
+func TestSpawnRetries(t *testing.T) {
+	hook, attempts := failSpawns(2, syscall.EAGAIN)
+	startDaemon(t, "serve", nil, &godaemonizer.Config{SpawnRetries: 2, PreStart: hook})
+	if *attempts != 3 {
+		t.Errorf("%d attempts, want 3", *attempts)
+	}
+}
+
+// TestSpawnRetriesPermanent checks that other failures are not retried.
+func TestSpawnRetriesPermanent(t *testing.T) {
+	hook, attempts := failSpawns(1, syscall.ENOENT)
+	d := godaemonizer.New(godaemonizer.WithName("serve"))
+	err := d.Daemonize(context.Background(), nil, &godaemonizer.Config{SpawnRetries: 2, PreStart: hook})
+	if !errors.Is(err, syscall.ENOENT) {
+		t.Fatalf("Daemonize: %v, want %v", err, syscall.ENOENT)
+	}
+	if *attempts != 1 {
+		t.Errorf("%d attempts, want 1", *attempts)
+	}
+}
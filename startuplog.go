@@ -0,0 +1,140 @@
+package daemonizer
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+)
+
+// Values of status.Log, which marks the progress messages of a
+// StartupLogWriter.
+const (
+	logLine = "line"
+	logEnd  = "end"
+)
+
+// StartupLogWriter returns a writer that streams the daemon's startup log
+// to the parent, e.g. as the output of a line-oriented logging library.
+// Each line written is sent as a progress message, so the parent sees it
+// through Config.OnProgress and Events as well as in Config.StartupLog.
+// Partial lines are held back until their newline. Closing the writer
+// sends the last partial line and tells the parent the log is done; ready
+// closes it too. Writes after that fail with os.ErrClosed. Every call
+// returns the same writer. Called by the daemon process after
+// WaitForParent.
+func (d *Daemon) StartupLogWriter() io.WriteCloser {
+	d.outMu.Lock()
+	defer d.outMu.Unlock()
+
+	if d.startLog == nil {
+		d.startLog = &startupLogWriter{d: d}
+	}
+	return d.startLog
+}
+
+// startupLogWriter is the daemon's side of the startup log.
+type startupLogWriter struct {
+	d      *Daemon
+	mu     sync.Mutex
+	buf    []byte // a partial line
+	closed bool
+}
+
+func (w *startupLogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed || w.d.reported.Load() {
+		return 0, os.ErrClosed
+	}
+	w.buf = append(w.buf, p...)
+	for {
+		line, rest, ok := bytes.Cut(w.buf, []byte("\n"))
+		if !ok {
+			break
+		}
+		if err := w.send(logLine, string(line)); err != nil {
+			return 0, err
+		}
+		w.buf = rest
+	}
+	return len(p), nil
+}
+
+func (w *startupLogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if len(w.buf) > 0 {
+		if err := w.send(logLine, string(w.buf)); err != nil {
+			return err
+		}
+		w.buf = nil
+	}
+	return w.send(logEnd, "")
+}
+
+func (w *startupLogWriter) send(kind, message string) error {
+	return w.d.send(status{Type: w.d.types.Progress, Message: message, Log: kind})
+}
+
+// closeStartupLog closes the startup log, if the daemon opened one, before
+// the startup report ends it.
+func (d *Daemon) closeStartupLog() {
+	d.outMu.Lock()
+	w := d.startLog
+	d.outMu.Unlock()
+
+	if w != nil {
+		w.Close()
+	}
+}
+
+// startupLog is the parent's side: it copies the lines of the daemon's
+// startup log to Config.StartupLog, and closes that once the log is done,
+// if it is an io.Closer.
+type startupLog struct {
+	mu   sync.Mutex
+	w    io.Writer
+	done bool
+}
+
+func newStartupLog(cfg *Config) *startupLog {
+	if cfg == nil || cfg.StartupLog == nil {
+		return nil
+	}
+	return &startupLog{w: cfg.StartupLog}
+}
+
+func (l *startupLog) line(message string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.done {
+		io.WriteString(l.w, message+"\n")
+	}
+}
+
+func (l *startupLog) end() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.done {
+		return
+	}
+	l.done = true
+	if c, ok := l.w.(io.Closer); ok {
+		c.Close()
+	}
+}
@@ -0,0 +1,39 @@
+package daemonizer
+
+import "encoding/json"
+
+// OnDumpState registers fn to serialize the daemon's in-memory state, e.g.
+// a counter or a cache snapshot, when the parent restarts it with
+// Config.TransferState. The new daemon gets the bytes in OnLoadState.
+// Register it before calling ready. Called by the daemon process.
+func (d *Daemon) OnDumpState(fn func() ([]byte, error)) {
+	d.handle(methodState, func(json.RawMessage) (any, error) {
+		return fn()
+	})
+}
+
+// OnLoadState registers fn to restore the state the previous daemon dumped
+// with OnDumpState. WaitForParent calls it once it has read the params, and
+// before the OnParams handler, unless there is no state, e.g. on the first
+// start or after a daemon that could not dump its state. An error from fn
+// fails the startup like one from OnParams. Register it before
+// WaitForParent. Called by the daemon process.
+func (d *Daemon) OnLoadState(fn func(state []byte) error) {
+	d.loadState = fn
+}
+
+// dumpState asks the running daemon for its state before a restart, if cfg
+// asks for it. A daemon that cannot answer, e.g. because it crashed or is
+// wedged, must still be restarted, so the failure is logged and the new
+// daemon starts without state.
+func (d *Daemon) dumpState(cfg *Config) []byte {
+	if cfg == nil || !cfg.TransferState {
+		return nil
+	}
+	var state []byte
+	if err := d.Call(methodState, nil, &state); err != nil {
+		d.logger.Warn("dump daemon state; restarting without it", "error", err)
+		return nil
+	}
+	return state
+}
@@ -0,0 +1,114 @@
+package daemonizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"time"
+)
+
+// defaultStatusFileInterval is how often the status file is refreshed
+// unless Config.StatusFileInterval says otherwise.
+const defaultStatusFileInterval = 10 * time.Second
+
+// DaemonStatus is the content of the file Config.StatusFile names.
+type DaemonStatus struct {
+	PID           int            `json:"pid"`
+	Started       time.Time      `json:"started"`
+	Updated       time.Time      `json:"updated"` // when the file was last written
+	UptimeSeconds float64        `json:"uptime_seconds"`
+	Ready         bool           `json:"ready"`
+	Fields        map[string]any `json:"fields,omitempty"` // see SetStatusField
+}
+
+// ReadStatusFile reads a status file written by a daemon started with
+// Config.StatusFile. A file whose Updated time is much older than the
+// refresh interval belongs to a daemon that is gone or hung.
+func ReadStatusFile(path string) (*DaemonStatus, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var st DaemonStatus
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("invalid status file %s: %w", path, err)
+	}
+	return &st, nil
+}
+
+// SetStatusField adds key to the Fields of the daemon's status file, or
+// replaces it, and rewrites the file. value must be JSON-serializable; a
+// nil value removes the key. Without Config.StatusFile it only records the
+// field. Called by the daemon process.
+func (d *Daemon) SetStatusField(key string, value any) {
+	d.statusMu.Lock()
+	if value == nil {
+		delete(d.statusFields, key)
+	} else {
+		if d.statusFields == nil {
+			d.statusFields = make(map[string]any)
+		}
+		d.statusFields[key] = value
+	}
+	d.statusMu.Unlock()
+
+	if err := d.writeStatusFile(); err != nil {
+		d.logger.Error("write status file", "error", err)
+	}
+}
+
+// startStatusFile writes the status file and keeps it fresh until the
+// daemon exits.
+func (d *Daemon) startStatusFile(path string, interval time.Duration) error {
+	d.statusMu.Lock()
+	d.statusFile = path
+	d.statusSince = time.Now()
+	d.statusMu.Unlock()
+
+	if err := d.writeStatusFile(); err != nil {
+		return err
+	}
+	go func() {
+		for range time.Tick(interval) {
+			if err := d.writeStatusFile(); err != nil {
+				d.logger.Error("write status file", "error", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// writeStatusFile replaces the status file, if there is one, atomically.
+func (d *Daemon) writeStatusFile() error {
+	d.statusMu.Lock()
+	defer d.statusMu.Unlock()
+
+	if d.statusFile == "" {
+		return nil
+	}
+	now := time.Now()
+	data, err := json.Marshal(DaemonStatus{
+		PID:           os.Getpid(),
+		Started:       d.statusSince,
+		Updated:       now,
+		UptimeSeconds: now.Sub(d.statusSince).Seconds(),
+		Ready:         d.isReady.Load(),
+		Fields:        maps.Clone(d.statusFields),
+	})
+	if err != nil {
+		return fmt.Errorf("encode status: %w", err)
+	}
+	return writeFileAtomic(d.statusFile, data, d.fsync)
+}
+
+// removeStatusFile removes the status file and stops refreshing it.
+func (d *Daemon) removeStatusFile() {
+	d.statusMu.Lock()
+	defer d.statusMu.Unlock()
+
+	if d.statusFile != "" {
+		os.Remove(d.statusFile)
+		d.statusFile = ""
+	}
+}
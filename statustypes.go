@@ -0,0 +1,52 @@
+package daemonizer
+
+import "fmt"
+
+// StatusTypes are the values of the "type" field that tell the messages on
+// the status pipe apart, for aligning the wire protocol with an external
+// schema. The parent sends its set to the daemon with the params, so both
+// ends always agree.
+type StatusTypes struct {
+	Report   string // the startup report
+	Progress string // ReportProgress messages
+	Reply    string // replies to control requests
+	Event    string // SendEvent messages
+	Shutdown string // the shutdown acknowledgement
+}
+
+// defaultStatusTypes is the protocol's own set; the startup report has no
+// type.
+var defaultStatusTypes = StatusTypes{
+	Report:   "",
+	Progress: "progress",
+	Reply:    "reply",
+	Event:    "event",
+	Shutdown: "shutdown",
+}
+
+// statusTypes returns the message types cfg selects.
+func statusTypes(cfg *Config) StatusTypes {
+	if cfg == nil || cfg.StatusTypes == nil {
+		return defaultStatusTypes
+	}
+	return *cfg.StatusTypes
+}
+
+// validate makes sure every message type is distinct, since the receiver
+// dispatches on them.
+func (t StatusTypes) validate() error {
+	seen := make(map[string]string, 5)
+	for _, kv := range [][2]string{
+		{"Report", t.Report},
+		{"Progress", t.Progress},
+		{"Reply", t.Reply},
+		{"Event", t.Event},
+		{"Shutdown", t.Shutdown},
+	} {
+		if other, ok := seen[kv[1]]; ok {
+			return fmt.Errorf("status types %s and %s are both %q", other, kv[0], kv[1])
+		}
+		seen[kv[1]] = kv[0]
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package daemonizer_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+func init() {
+	roles["small-params"] = func(*godaemonizer.Daemon) {
+		d := godaemonizer.New(godaemonizer.WithMaxParamBytes(16))
+		if _, err := d.WaitForParent(nil); err == nil {
+			os.Exit(1)
+		}
+	}
+}
+
+// TestCustomTypesEarlyFailure checks that a failure the daemon reports
+// before it has read the custom types still reaches the parent.
+func TestCustomTypesEarlyFailure(t *testing.T) {
+	types := &godaemonizer.StatusTypes{
+		Report:   "report",
+		Progress: "progress",
+		Reply:    "reply",
+		Event:    "event",
+		Shutdown: "shutdown",
+	}
+	d := godaemonizer.New(godaemonizer.WithName("small-params"))
+	t.Cleanup(func() { d.Kill() })
+	params := map[string]any{"padding": strings.Repeat("x", 64)}
+	err := d.Daemonize(context.Background(), params, &godaemonizer.Config{StatusTypes: types})
+
+	var de *godaemonizer.DaemonError
+	if !errors.As(err, &de) {
+		t.Fatalf("Daemonize: %v, want a DaemonError", err)
+	}
+	if !strings.Contains(de.Message, godaemonizer.ErrParamsTooLarge.Error()) {
+		t.Errorf("daemon error %q does not mention %q", de.Message, godaemonizer.ErrParamsTooLarge)
+	}
+}
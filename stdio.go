@@ -0,0 +1,45 @@
+package daemonizer
+
+import "os"
+
+// The stdio helpers set one stream each, so they compose into any mix,
+// e.g. InheritStderr alone keeps errors visible while stdin and stdout stay
+// on /dev/null, which is where streams left unset go.
+
+// InheritStdin connects the daemon's stdin to the parent's.
+func (c *Config) InheritStdin() {
+	c.Stdin, c.StdinData = os.Stdin, nil
+}
+
+// InheritStdout connects the daemon's stdout to the parent's.
+func (c *Config) InheritStdout() {
+	c.Stdout, c.StdoutPath = os.Stdout, ""
+}
+
+// InheritStderr connects the daemon's stderr to the parent's.
+func (c *Config) InheritStderr() {
+	c.Stderr, c.StderrPath = os.Stderr, ""
+}
+
+// InheritStdio connects all three of the daemon's standard streams to the
+// parent's.
+func (c *Config) InheritStdio() {
+	c.InheritStdin()
+	c.InheritStdout()
+	c.InheritStderr()
+}
+
+// NullStdin connects the daemon's stdin to /dev/null.
+func (c *Config) NullStdin() {
+	c.Stdin, c.StdinData = nil, nil
+}
+
+// NullStdout connects the daemon's stdout to /dev/null.
+func (c *Config) NullStdout() {
+	c.Stdout, c.StdoutPath = nil, ""
+}
+
+// NullStderr connects the daemon's stderr to /dev/null.
+func (c *Config) NullStderr() {
+	c.Stderr, c.StderrPath = nil, ""
+}
@@ -0,0 +1,141 @@
+package daemonizer_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+func init() {
+	roles["print"] = func(d *godaemonizer.Daemon) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		fmt.Println("to stdout")
+		fmt.Fprintln(os.Stderr, "to stderr")
+		ready(nil)
+	}
+	roles["read-stdin"] = func(d *godaemonizer.Daemon) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			ready(err)
+			return
+		}
+		d.SetReadyData(data)
+		ready(nil)
+	}
+	roles["stdio-targets"] = func(d *godaemonizer.Daemon) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		fmt.Println("to stdout")
+		var targets []string
+		for fd := range 3 {
+			target, _ := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", fd))
+			targets = append(targets, target)
+		}
+		d.SetReadyData(targets)
+		ready(nil)
+	}
+}
+
+// TestStdioFiles checks that the daemon writes to files the parent opened,
+// and that CloseStdio leaves the daemon as their only holder.
+func TestStdioFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	open := func() *os.File {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	stdout, stderr := open(), open()
+	d := startDaemon(t, "print", nil, &godaemonizer.Config{Stdout: stdout, Stderr: stderr, CloseStdio: true})
+	<-d.Done()
+
+	for _, f := range []*os.File{stdout, stderr} {
+		if _, err := f.Write([]byte("parent\n")); !errors.Is(err, os.ErrClosed) {
+			t.Errorf("parent's copy of %s: write error %v, want %v", f.Name(), err, os.ErrClosed)
+		}
+	}
+	log, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"to stdout", "to stderr"} {
+		if !strings.Contains(string(log), line) {
+			t.Errorf("log %q lacks %q", log, line)
+		}
+	}
+}
+
+// TestMixedStdio checks a daemon with its stdout inherited from the parent
+// and its stderr on /dev/null. It needs /proc.
+func TestMixedStdio(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	parentStdout := os.Stdout
+	os.Stdout = w
+	cfg := &godaemonizer.Config{}
+	cfg.InheritStdout()
+	cfg.NullStderr()
+	os.Stdout = parentStdout
+
+	d := godaemonizer.New(godaemonizer.WithName("stdio-targets"))
+	t.Cleanup(func() { d.Kill() })
+	var targets []string
+	err = d.DaemonizeWithData(context.Background(), nil, cfg, &targets)
+	w.Close()
+	if err != nil {
+		t.Fatalf("Daemonize: %v", err)
+	}
+	if len(targets) != 3 || targets[1] == "" {
+		t.Skipf("daemon could not tell its fds apart: %q", targets)
+	}
+	if targets[2] != os.DevNull {
+		t.Errorf("daemon stderr is %s, want %s", targets[2], os.DevNull)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "to stdout\n" {
+		t.Errorf("parent's stdout got %q, want %q", out, "to stdout\n")
+	}
+}
+
+// TestStdinData checks that the daemon reads exactly Config.StdinData on
+// its stdin, more than a pipe buffer of it, followed by EOF.
+func TestStdinData(t *testing.T) {
+	data := make([]byte, 256<<10)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	d := godaemonizer.New(godaemonizer.WithName("read-stdin"))
+	t.Cleanup(func() { d.Kill() })
+	var got []byte
+	if err := d.DaemonizeWithData(context.Background(), nil, &godaemonizer.Config{StdinData: data}, &got); err != nil {
+		t.Fatalf("Daemonize: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("daemon read %d bytes of stdin, not the %d sent", len(got), len(data))
+	}
+}
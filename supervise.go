@@ -0,0 +1,189 @@
+package daemonizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// Defaults for the zero fields of a RestartPolicy.
+const (
+	defaultHealthInterval  = 10 * time.Second
+	defaultHealthThreshold = 3
+	defaultInitialBackoff  = time.Second
+	defaultMaxBackoff      = time.Minute
+)
+
+// RestartPolicy tells Supervise when to restart the daemon.
+type RestartPolicy struct {
+	// HealthCheck probes the daemon, e.g. by querying a health endpoint,
+	// and returns an error if it is not serving. It should honor ctx,
+	// which expires after Interval. Required.
+	HealthCheck func(ctx context.Context) error
+	Interval    time.Duration // between checks (default 10s)
+	Threshold   int           // consecutive failures that trigger a restart (default 3)
+	Grace       time.Duration // passed to Restart to stop the daemon
+
+	// OnRestart, if set, is called before each restart with the error of
+	// the last failed check.
+	OnRestart func(err error)
+
+	// A daemon that needs restarting again within HealthyAfter of its last
+	// restart waits before the restart, InitialBackoff the first time and
+	// twice as long each further time, up to MaxBackoff. Once a daemon has
+	// stayed up for HealthyAfter, its next restart is immediate again.
+	InitialBackoff time.Duration // default 1s
+	MaxBackoff     time.Duration // default 1m
+	Jitter         float64       // fraction of each pause added or taken at random, 0 to 1
+	HealthyAfter   time.Duration // default MaxBackoff
+}
+
+// validate checks the fields that have no default.
+func (p *RestartPolicy) validate() error {
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return fmt.Errorf("RestartPolicy.Jitter %v outside 0..1", p.Jitter)
+	}
+	return nil
+}
+
+// restartBackoff paces the restarts of one daemon by a RestartPolicy.
+type restartBackoff struct {
+	initial, max, healthyAfter time.Duration
+	jitter                     float64
+	delay                      time.Duration // before the next quick restart
+}
+
+// newRestartBackoff returns the backoff of policy, which may be nil for
+// the defaults.
+func newRestartBackoff(policy *RestartPolicy) *restartBackoff {
+	b := &restartBackoff{initial: defaultInitialBackoff, max: defaultMaxBackoff}
+	if policy != nil {
+		if policy.InitialBackoff > 0 {
+			b.initial = policy.InitialBackoff
+		}
+		if policy.MaxBackoff > 0 {
+			b.max = policy.MaxBackoff
+		}
+		b.healthyAfter = policy.HealthyAfter
+		b.jitter = policy.Jitter
+	}
+	b.initial = min(b.initial, b.max)
+	if b.healthyAfter <= 0 {
+		b.healthyAfter = b.max
+	}
+	return b
+}
+
+// next returns the pause before restarting a daemon that has been up for
+// uptime, and doubles the pause for the one after.
+func (b *restartBackoff) next(uptime time.Duration) time.Duration {
+	if uptime >= b.healthyAfter {
+		b.delay = 0
+	}
+	pause := b.delay
+	b.delay = min(max(2*b.delay, b.initial), b.max)
+	if b.jitter > 0 {
+		pause += time.Duration((2*rand.Float64() - 1) * b.jitter * float64(pause))
+	}
+	return pause
+}
+
+// sleep waits for pause, or until ctx is done, and reports whether the
+// pause is over.
+func sleep(ctx context.Context, pause time.Duration) bool {
+	if pause <= 0 {
+		return true
+	}
+	timer := time.NewTimer(pause)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Supervise checks the daemon's health with policy.HealthCheck every
+// policy.Interval and restarts it with Restart once policy.Threshold checks
+// in a row have failed. This catches a daemon that is still running but
+// wedged, which watching for its exit misses; a daemon that exited fails its
+// checks too and is restarted the same way. The count starts over after each
+// passing check and after each restart. Restarts that follow each other
+// quickly are spaced out by the policy's backoff.
+//
+// Supervise blocks until ctx is done, then returns ctx.Err(), or until a
+// restart fails, then returns that error with the daemon left as Restart
+// left it. Called by the parent process after a successful Daemonize.
+func (d *Daemon) Supervise(ctx context.Context, policy RestartPolicy) error {
+	if policy.HealthCheck == nil {
+		return errors.New("RestartPolicy.HealthCheck is required")
+	}
+	if err := policy.validate(); err != nil {
+		return err
+	}
+	if d.cmd == nil {
+		return ErrNotStarted
+	}
+	if d.launched != nil {
+		if err := d.launched.cfg.reusable(); err != nil {
+			return err
+		}
+	}
+	interval := policy.Interval
+	if interval <= 0 {
+		interval = defaultHealthInterval
+	}
+	threshold := policy.Threshold
+	if threshold <= 0 {
+		threshold = defaultHealthThreshold
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	backoff := newRestartBackoff(&policy)
+	since := time.Now()
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, interval)
+		err := policy.HealthCheck(checkCtx)
+		cancel()
+		if err == nil {
+			failures = 0
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		failures++
+		d.logger.Warn("daemon health check failed", "failures", failures, "error", err)
+		if failures < threshold {
+			continue
+		}
+
+		if pause := backoff.next(time.Since(since)); pause > 0 {
+			d.logger.Info("backing off before restart", "pause", pause)
+			if !sleep(ctx, pause) {
+				return ctx.Err()
+			}
+		}
+		if policy.OnRestart != nil {
+			policy.OnRestart(err)
+		}
+		d.logger.Info("restarting unhealthy daemon", "error", err)
+		if err := d.Restart(ctx, policy.Grace); err != nil {
+			return fmt.Errorf("restart unhealthy daemon: %w", err)
+		}
+		since = time.Now()
+		failures = 0
+	}
+}
@@ -0,0 +1,37 @@
+package daemonizer
+
+import (
+	"testing"
+	"time"
+)
+
+// TestRestartBackoff checks that the pause before a quick restart doubles
+// up to MaxBackoff, stays within Jitter of that, and starts over once the
+// daemon has stayed up for HealthyAfter.
+func TestRestartBackoff(t *testing.T) {
+	b := newRestartBackoff(&RestartPolicy{
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     50 * time.Millisecond,
+		HealthyAfter:   time.Second,
+	})
+	ms := time.Millisecond
+	for i, want := range []time.Duration{0, 10 * ms, 20 * ms, 40 * ms, 50 * ms, 50 * ms} {
+		if got := b.next(0); got != want {
+			t.Errorf("pause %d = %v, want %v", i, got, want)
+		}
+	}
+	if got := b.next(time.Second); got != 0 {
+		t.Errorf("pause after HealthyAfter = %v, want 0", got)
+	}
+	if got := b.next(0); got != 10*ms {
+		t.Errorf("pause after a reset = %v, want %v", got, 10*ms)
+	}
+
+	b = newRestartBackoff(&RestartPolicy{InitialBackoff: 100 * ms, MaxBackoff: 100 * ms, Jitter: 0.5})
+	b.next(0)
+	for range 100 {
+		if got := b.next(0); got < 50*ms || got > 150*ms {
+			t.Fatalf("pause with jitter 0.5 = %v, want within 50ms of 100ms", got)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package daemonizer_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+// sickenAfter is how long the "sicken" daemon passes its health checks.
+const sickenAfter = 300 * time.Millisecond
+
+func init() {
+	roles["sicken"] = func(d *godaemonizer.Daemon) {
+		start := time.Now()
+		d.RegisterMethod("health", func(json.RawMessage) (any, error) {
+			if time.Since(start) > sickenAfter {
+				return nil, errors.New("unhealthy")
+			}
+			return "ok", nil
+		})
+		serve(d)
+	}
+}
+
+// TestSuperviseUnhealthyAfterDelay checks that Supervise leaves a daemon
+// alone while it passes its health checks, and restarts it once it fails
+// them.
+func TestSuperviseUnhealthyAfterDelay(t *testing.T) {
+	started := time.Now()
+	d := startDaemon(t, "sicken", nil, &godaemonizer.Config{Control: true})
+	pid := d.PID()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var restarted time.Time
+	var reason error
+	policy := godaemonizer.RestartPolicy{
+		HealthCheck: func(context.Context) error {
+			// the first check after the restart ends the test
+			if !restarted.IsZero() {
+				cancel()
+			}
+			return d.Call("health", nil, nil)
+		},
+		Interval:  20 * time.Millisecond,
+		Threshold: 2,
+		OnRestart: func(err error) { restarted, reason = time.Now(), err },
+	}
+	if err := d.Supervise(ctx, policy); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Supervise: %v, want %v", err, context.Canceled)
+	}
+	if healthy := restarted.Sub(started); healthy < sickenAfter {
+		t.Errorf("restarted %v after the start, while the daemon was still healthy", healthy)
+	}
+	if !errors.Is(reason, godaemonizer.ErrRequestFailed) {
+		t.Errorf("restarted for %v, want the failed health check", reason)
+	}
+	if d.PID() == pid {
+		t.Errorf("Restart kept pid %d", pid)
+	}
+	waitExited(t, pid)
+}
+
+// TestSuperviseBackoff checks that a daemon that keeps failing its health
+// checks right after each restart is restarted after longer and longer
+// pauses, up to MaxBackoff.
+func TestSuperviseBackoff(t *testing.T) {
+	d := startDaemon(t, "serve", nil, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var mu sync.Mutex
+	var restarts []time.Time
+	policy := godaemonizer.RestartPolicy{
+		HealthCheck:    func(context.Context) error { return errors.New("unhealthy") },
+		Interval:       5 * time.Millisecond,
+		Threshold:      1,
+		InitialBackoff: 20 * time.Millisecond,
+		MaxBackoff:     80 * time.Millisecond,
+		HealthyAfter:   time.Minute,
+		OnRestart: func(error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if restarts = append(restarts, time.Now()); len(restarts) == 5 {
+				cancel()
+			}
+		},
+	}
+	if err := d.Supervise(ctx, policy); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Supervise: %v, want %v", err, context.Canceled)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	ms := time.Millisecond
+	for i, want := range []time.Duration{20 * ms, 40 * ms, 80 * ms, 80 * ms} {
+		if gap := restarts[i+1].Sub(restarts[i]); gap < want {
+			t.Errorf("restart %d came %v after the one before, want at least %v", i+2, gap, want)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package daemonizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DaemonSpec describes one daemon run by a Supervisor.
+type DaemonSpec struct {
+	// Name identifies the daemon in Status and is its role, as set with
+	// WithName, so that the daemon can tell which one it is. Required and
+	// unique within a Supervisor.
+	Name    string
+	Params  any
+	Config  *Config
+	Options []Option // for New, e.g. WithLogger
+
+	// Policy, if set, adds health checks to the exit watching; its
+	// HealthCheck may then be nil to set only Grace, OnRestart and the
+	// backoff.
+	Policy *RestartPolicy
+}
+
+// SupervisedDaemon is the state of one daemon of a Supervisor.
+type SupervisedDaemon struct {
+	Name     string
+	PID      int
+	Running  bool
+	Restarts int
+
+	// LastError is why the daemon was last restarted or why the last
+	// attempt to restart it failed, nil if it never was.
+	LastError error
+}
+
+// Supervisor keeps a set of daemons running, a small process manager on top
+// of Daemonize: Run restarts each daemon that exits or, with a health check,
+// stops serving. Every method is called by the parent process.
+type Supervisor struct {
+	mu       sync.Mutex
+	members  []*member
+	started  bool
+	running  bool
+	stopped  bool
+	halt     chan struct{} // closed by Stop
+	watchers sync.WaitGroup
+}
+
+// member is a daemon of a Supervisor; d, restarts, lastErr and since are
+// guarded by Supervisor.mu.
+type member struct {
+	spec     DaemonSpec
+	d        *Daemon
+	restarts int
+	lastErr  error
+	since    time.Time // when d became ready
+	backoff  *restartBackoff
+}
+
+// NewSupervisor returns a Supervisor for specs, which it starts in order.
+func NewSupervisor(specs ...DaemonSpec) (*Supervisor, error) {
+	s := &Supervisor{halt: make(chan struct{})}
+	names := make(map[string]bool)
+	for _, spec := range specs {
+		if spec.Name == "" {
+			return nil, errors.New("DaemonSpec.Name is required")
+		}
+		if names[spec.Name] {
+			return nil, fmt.Errorf("duplicate daemon name %q", spec.Name)
+		}
+		names[spec.Name] = true
+		if err := spec.Config.reusable(); err != nil {
+			return nil, fmt.Errorf("daemon %s: %w", spec.Name, err)
+		}
+		if spec.Policy != nil {
+			if err := spec.Policy.validate(); err != nil {
+				return nil, fmt.Errorf("daemon %s: %w", spec.Name, err)
+			}
+		}
+		opts := append(append([]Option(nil), spec.Options...), WithName(spec.Name))
+		s.members = append(s.members, &member{spec: spec, d: New(opts...), backoff: newRestartBackoff(spec.Policy)})
+	}
+	return s, nil
+}
+
+// Start daemonizes every daemon, one after the other, and waits for each to
+// become ready. If one fails, the ones already running are stopped and its
+// error is returned. Start may only be called once.
+func (s *Supervisor) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return ErrClosed
+	}
+	if s.started {
+		return ErrDaemonAlreadyStarted
+	}
+
+	for i, m := range s.members {
+		if err := m.d.Daemonize(ctx, m.spec.Params, m.spec.Config); err != nil {
+			for _, prev := range s.members[:i] {
+				prev.d.Stop(cancelGrace(prev.spec.Config))
+			}
+			return fmt.Errorf("daemon %s: %w", m.spec.Name, err)
+		}
+		m.since = time.Now()
+	}
+	s.started = true
+	return nil
+}
+
+// Run starts the daemons, unless Start already did, and keeps them running:
+// a daemon that exits is started again with the same params and Config, as
+// is one that fails Policy.Threshold health checks in a row, after a Stop
+// with Policy.Grace. A restart that fails is retried, and a daemon that
+// fails again soon after a restart waits longer each time, as set by the
+// backoff fields of Policy.
+//
+// Run blocks until ctx is done, then returns ctx.Err() with the daemons
+// left running, or until Stop is called, then returns nil.
+func (s *Supervisor) Run(ctx context.Context) error {
+	s.mu.Lock()
+	started := s.started
+	s.mu.Unlock()
+	if !started {
+		if err := s.Start(ctx); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return nil
+	}
+	if s.running {
+		s.mu.Unlock()
+		return errors.New("supervisor already running")
+	}
+	s.running = true
+	s.watchers.Add(len(s.members))
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.running = false
+		s.mu.Unlock()
+	}()
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-s.halt:
+			cancel()
+		case <-watchCtx.Done():
+		}
+	}()
+	for _, m := range s.members {
+		go func() {
+			defer s.watchers.Done()
+			s.watch(watchCtx, m)
+		}()
+	}
+	s.watchers.Wait()
+
+	select {
+	case <-s.halt:
+		return nil
+	default:
+		return ctx.Err()
+	}
+}
+
+// Stop ends Run, waiting for a restart in progress to give up, and stops
+// every daemon like Stop(grace). It returns the errors of the daemons that
+// could not be stopped cleanly. A stopped Supervisor cannot be started
+// again.
+func (s *Supervisor) Stop(grace time.Duration) error {
+	s.mu.Lock()
+	if !s.stopped {
+		s.stopped = true
+		close(s.halt)
+	}
+	s.mu.Unlock()
+	s.watchers.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	for _, m := range s.members {
+		if m.d.cmd == nil {
+			continue
+		}
+		if err := m.d.Stop(grace); err != nil {
+			errs = append(errs, fmt.Errorf("daemon %s: %w", m.spec.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Status returns the state of every daemon, in the order of the specs.
+func (s *Supervisor) Status() []SupervisedDaemon {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := make([]SupervisedDaemon, len(s.members))
+	for i, m := range s.members {
+		status[i] = SupervisedDaemon{
+			Name:      m.spec.Name,
+			PID:       m.d.PID(),
+			Running:   m.d.cmd != nil && m.d.ExitState() == nil,
+			Restarts:  m.restarts,
+			LastError: m.lastErr,
+		}
+	}
+	return status
+}
+
+// Daemon returns the Daemon that currently manages the daemon name, e.g.
+// for Call or Reload, or nil if there is no such daemon. A restart
+// replaces it.
+func (s *Supervisor) Daemon(name string) *Daemon {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range s.members {
+		if m.spec.Name == name {
+			return m.d
+		}
+	}
+	return nil
+}
+
+// current returns the Daemon that manages m now.
+func (s *Supervisor) current(m *member) *Daemon {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return m.d
+}
+
+// watch restarts m's daemon whenever it exits or fails its health checks,
+// until ctx is done.
+func (s *Supervisor) watch(ctx context.Context, m *member) {
+	policy := m.spec.Policy
+	var tick <-chan time.Time
+	interval, threshold := defaultHealthInterval, defaultHealthThreshold
+	if policy != nil && policy.HealthCheck != nil {
+		if policy.Interval > 0 {
+			interval = policy.Interval
+		}
+		if policy.Threshold > 0 {
+			threshold = policy.Threshold
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	failures := 0
+	for {
+		d := s.current(m)
+		var reason error
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.Done():
+			reason = exitReason(d)
+		case <-tick:
+			checkCtx, cancel := context.WithTimeout(ctx, interval)
+			err := policy.HealthCheck(checkCtx)
+			cancel()
+			if err == nil {
+				failures = 0
+				continue
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			failures++
+			d.logger.Warn("daemon health check failed", "name", m.spec.Name, "failures", failures, "error", err)
+			if failures < threshold {
+				continue
+			}
+			reason = fmt.Errorf("health check: %w", err)
+		}
+
+		failures = 0
+		if !s.restart(ctx, m, reason) {
+			return
+		}
+	}
+}
+
+// exitReason describes how d's daemon exited.
+func exitReason(d *Daemon) error {
+	if state := d.ExitState(); state != nil {
+		return fmt.Errorf("daemon exited: %v", state)
+	}
+	return errors.New("daemon exited")
+}
+
+// restart stops m's daemon and starts a fresh one in its place, retrying
+// with a growing delay until it succeeds or ctx is done. It reports whether
+// a new daemon is running.
+func (s *Supervisor) restart(ctx context.Context, m *member, reason error) bool {
+	s.mu.Lock()
+	m.lastErr = reason
+	d, since := m.d, m.since
+	s.mu.Unlock()
+
+	var grace time.Duration
+	if policy := m.spec.Policy; policy != nil {
+		grace = policy.Grace
+		if policy.OnRestart != nil {
+			policy.OnRestart(reason)
+		}
+	}
+
+	state := d.dumpState(m.spec.Config)
+	uptime := time.Since(since)
+	for {
+		if !sleep(ctx, m.backoff.next(uptime)) {
+			return false
+		}
+		uptime = 0 // a failed attempt never came up
+
+		d.logger.Info("restarting daemon", "name", m.spec.Name, "reason", reason)
+		err := d.Stop(grace)
+		if err != nil && !errors.Is(err, ErrStopKilled) {
+			err = fmt.Errorf("stop daemon: %w", err)
+		} else {
+			next := d.sibling()
+			next.state = state
+			if err = next.Daemonize(ctx, m.spec.Params, m.spec.Config); err == nil {
+				s.mu.Lock()
+				m.d = next
+				m.restarts++
+				m.since = time.Now()
+				s.mu.Unlock()
+				return true
+			}
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		d.logger.Error("restart daemon", "name", m.spec.Name, "error", err)
+		s.mu.Lock()
+		m.lastErr = err
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,125 @@
+package daemonizer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// tailGrace is how long past Config.TailDuration the parent keeps copying,
+// for output the daemon wrote just before switching.
+const tailGrace = time.Second
+
+// tailSpec tells the daemon when to move its stdout and stderr off the
+// tail pipe, and which inherited fds hold their final destinations.
+type tailSpec struct {
+	For    time.Duration `json:"for"`
+	Stdout int           `json:"stdout"`
+	Stderr int           `json:"stderr"`
+}
+
+// startupTail is the parent side of Config.TailOutput: a pipe the daemon
+// writes its output to during the window, copied to the writer, and the
+// final destinations the daemon switches to afterwards.
+type startupTail struct {
+	out            io.Writer
+	window         time.Duration
+	r, w           *os.File
+	stdout, stderr *os.File
+	opened         []*os.File // the parent's own copies, closed by release
+	copying        bool
+}
+
+// openTail prepares Config.TailOutput. stdout and stderr are the final
+// destinations, nil meaning /dev/null. It returns nil if no tail is wanted.
+func openTail(cfg *Config, stdout, stderr *os.File) (*startupTail, error) {
+	if cfg == nil || cfg.TailOutput == nil {
+		return nil, nil
+	}
+
+	t := &startupTail{out: cfg.TailOutput, window: cfg.TailDuration, stdout: stdout, stderr: stderr}
+	for _, f := range []**os.File{&t.stdout, &t.stderr} {
+		if *f != nil {
+			continue
+		}
+		null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		if err != nil {
+			t.release()
+			return nil, fmt.Errorf("open tail destination: %w", err)
+		}
+		*f = null
+		t.opened = append(t.opened, null)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.release()
+		return nil, fmt.Errorf("create tail pipe: %w", err)
+	}
+	t.r, t.w = r, w
+	t.opened = append(t.opened, w)
+	return t, nil
+}
+
+// attach points the daemon's stdout and stderr at the tail pipe and passes
+// the final destinations after all other inherited files.
+func (t *startupTail) attach(cmd *exec.Cmd) {
+	if t == nil {
+		return
+	}
+	cmd.Stdout, cmd.Stderr = t.w, t.w
+	cmd.ExtraFiles = append(cmd.ExtraFiles, t.stdout, t.stderr)
+}
+
+// spec describes t to the daemon, whose inherited files end at fd next.
+func (t *startupTail) spec(next int) *tailSpec {
+	if t == nil {
+		return nil
+	}
+	return &tailSpec{For: t.window, Stdout: next, Stderr: next + 1}
+}
+
+// copy copies the daemon's output to the writer until the daemon switches
+// away from the pipe, or the window is well over. Call it once the daemon
+// has started.
+func (t *startupTail) copy() {
+	if t == nil {
+		return
+	}
+	t.copying = true
+	t.r.SetReadDeadline(time.Now().Add(t.window + tailGrace))
+	go func() {
+		defer t.r.Close()
+		io.Copy(t.out, t.r)
+	}()
+}
+
+// release closes the parent's copies of the tail files, all of them unless
+// copyTo took over the read end.
+func (t *startupTail) release() {
+	if t == nil {
+		return
+	}
+	for _, f := range t.opened {
+		f.Close()
+	}
+	if t.r != nil && !t.copying {
+		t.r.Close()
+	}
+}
+
+// schedule moves the daemon's stdout and stderr to their final
+// destinations once the window is over.
+func (s *tailSpec) schedule() error {
+	for _, fd := range []int{s.Stdout, s.Stderr} {
+		if err := keepPrivate(fd); err != nil {
+			return err
+		}
+	}
+	time.AfterFunc(s.For, func() {
+		redirectStdio(s.Stdout, s.Stderr)
+	})
+	return nil
+}
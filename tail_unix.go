@@ -0,0 +1,27 @@
+//go:build unix
+
+package daemonizer
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// keepPrivate stops an inherited fd from leaking into programs the daemon
+// executes.
+func keepPrivate(fd int) error {
+	if _, err := unix.FcntlInt(uintptr(fd), unix.F_SETFD, unix.FD_CLOEXEC); err != nil {
+		return fmt.Errorf("inherited fd %d: %w", fd, err)
+	}
+	return nil
+}
+
+// redirectStdio moves fds 1 and 2 onto stdout and stderr, which it then
+// closes.
+func redirectStdio(stdout, stderr int) {
+	unix.Dup2(stdout, 1)
+	unix.Dup2(stderr, 2)
+	unix.Close(stdout)
+	unix.Close(stderr)
+}
@@ -0,0 +1,9 @@
+package daemonizer
+
+import "errors"
+
+func keepPrivate(fd int) error {
+	return errors.New("tailing startup output is only supported on Unix")
+}
+
+func redirectStdio(stdout, stderr int) {}
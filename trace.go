@@ -0,0 +1,18 @@
+package daemonizer
+
+import "maps"
+
+// W3C Trace Context keys, for Config.TraceContext.
+const (
+	TraceParentKey = "traceparent"
+	TraceStateKey  = "tracestate"
+)
+
+// TraceContext returns the trace headers the parent sent in
+// Config.TraceContext, e.g. to extract the parent span with a propagator so
+// the daemon's spans link to the request that started it. It returns nil
+// if the parent sent none. The map is a copy.
+// Called by the daemon process after WaitForParent.
+func (d *Daemon) TraceContext() map[string]string {
+	return maps.Clone(d.trace)
+}
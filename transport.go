@@ -0,0 +1,259 @@
+package daemonizer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Transport selects how params are handed to the daemon on fd 3.
+type Transport int
+
+const (
+	// TransportPipe streams params through a pipe (default).
+	TransportPipe Transport = iota
+	// TransportMemfd hands over a sealed, memory-backed file holding the
+	// params (Linux only). Large configs cannot stall on the pipe buffer and
+	// the daemon can mmap them. Falls back to TransportPipe when memfds are
+	// unavailable.
+	TransportMemfd
+	// TransportSocketpair connects parent and daemon through one Unix
+	// socket pair, passed to the daemon as both fd 3 and fd 4, so a single
+	// bidirectional connection carries the handshake and any control
+	// traffic. Falls back to TransportPipe where socket pairs are
+	// unavailable.
+	TransportSocketpair
+)
+
+// ParamSource selects the fd the daemon reads its params from.
+type ParamSource int
+
+const (
+	// ParamSourcePipeFd hands params over on fd 3 (default).
+	ParamSourcePipeFd ParamSource = iota
+	// ParamSourceStdin hands params over on fd 0, for launchers that do
+	// not preserve other inherited fds. Config.Stdin must be nil; once the
+	// params are read the daemon's stdin is /dev/null.
+	ParamSourceStdin
+)
+
+// The names TransportUsed reports.
+const (
+	transportPipe       = "pipe"
+	transportMemfd      = "memfd"
+	transportSocketpair = "socketpair"
+	transportFile       = "file"
+)
+
+// paramFdEnv tells the daemon which fd to read params from when it is not
+// fd 3. The daemon removes it from its environment.
+const paramFdEnv = "DAEMONIZER_PARAM_FD"
+
+var (
+	errStdinInUse       = errors.New("Config.Stdin and Config.StdinData must be nil with ParamSourceStdin")
+	errParamFileControl = errors.New("Config.ParamFile cannot carry a control channel")
+	errParamFileSecrets = errors.New("Config.Secrets must not be written to Config.ParamFile")
+)
+
+func paramsOnStdin(cfg *Config) bool {
+	return cfg != nil && cfg.ParamSource == ParamSourceStdin
+}
+
+// attachPipes places the param and status pipes where the daemon expects
+// them, followed by cfg.ExtraFiles and the listener sockets. With
+// ParamSourceStdin fd 3 is left closed so the extra files keep their numbers.
+func attachPipes(cmd *exec.Cmd, paramR, statusW *os.File, cfg *Config, listeners []*os.File) {
+	cmd.ExtraFiles = []*os.File{paramR, statusW}
+	if paramsOnStdin(cfg) {
+		cmd.Stdin = paramR
+		cmd.ExtraFiles[0] = nil
+		env := cmd.Env
+		if env == nil {
+			env = os.Environ()
+		}
+		cmd.Env = mergeEnv(env, map[string]string{paramFdEnv: "0"})
+	}
+	if cfg != nil {
+		cmd.ExtraFiles = append(cmd.ExtraFiles, cfg.ExtraFiles...)
+	}
+	cmd.ExtraFiles = append(cmd.ExtraFiles, listeners...)
+}
+
+// paramFile returns the file the daemon reads params from, as chosen by the
+// parent through paramFdEnv.
+func paramFile() (*os.File, error) {
+	v, ok := os.LookupEnv(paramFdEnv)
+	if !ok {
+		pollable(3)
+		return os.NewFile(3, "param_pipe"), nil
+	}
+	os.Unsetenv(paramFdEnv)
+
+	fd, err := strconv.Atoi(v)
+	if err != nil || fd != 0 {
+		return nil, fmt.Errorf("invalid %s %q", paramFdEnv, v)
+	}
+	return detachStdin()
+}
+
+// openPipes returns the handshake files: the child's ends, paramR and
+// statusW, and the parent's, paramW and statusR. paramW is nil when the
+// transport already holds the payload.
+func (d *Daemon) openPipes(payload []byte, cfg *Config) (paramR, paramW, statusR, statusW *os.File, err error) {
+	if cfg != nil && cfg.Transport == TransportSocketpair && cfg.ParamFile == "" {
+		paramR, paramW, statusR, statusW, err = socketPipes()
+		if err == nil {
+			d.transport = transportSocketpair
+			return paramR, paramW, statusR, statusW, nil
+		}
+		d.logger.Debug("socketpair transport unavailable, falling back to pipes", "error", err)
+	}
+
+	paramR, paramW, err = d.openParams(payload, cfg)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	statusR, statusW, err = os.Pipe()
+	if err != nil {
+		paramR.Close()
+		closeIfOpen(paramW)
+		return nil, nil, nil, nil, pipeError("status pipe", err)
+	}
+	return paramR, paramW, statusR, statusW, nil
+}
+
+// openParams returns the file the child reads params from and, for the pipe
+// transport, the write end the parent sends payload into. w is nil when the
+// transport already holds the payload.
+func (d *Daemon) openParams(payload []byte, cfg *Config) (r, w *os.File, err error) {
+	if cfg != nil && cfg.ParamFile != "" {
+		f, err := fileParams(cfg.ParamFile, payload)
+		if err != nil {
+			return nil, nil, err
+		}
+		d.transport = transportFile
+		return f, nil, nil
+	}
+	// the control channel rides on the param pipe, so it rules out memfd
+	if cfg != nil && cfg.Transport == TransportMemfd && !cfg.Control {
+		f, err := memfdParams(payload)
+		if err == nil {
+			d.transport = transportMemfd
+			return f, nil, nil
+		}
+		d.logger.Debug("memfd transport unavailable, falling back to pipe", "error", err)
+	}
+
+	r, w, err = os.Pipe()
+	if err != nil {
+		return nil, nil, pipeError("param pipe", err)
+	}
+	d.transport = transportPipe
+	return r, w, nil
+}
+
+// pipeError describes a failed pipe creation, hinting at the fd limit when
+// that is what ran out.
+func pipeError(what string, err error) error {
+	if errors.Is(err, syscall.EMFILE) || errors.Is(err, syscall.ENFILE) {
+		return fmt.Errorf("create %s: %w (out of file descriptors; check the open file limit, e.g. ulimit -n)", what, err)
+	}
+	return fmt.Errorf("create %s: %w", what, err)
+}
+
+// protocolVersion is the version of the handshake and status protocol this
+// build speaks. Each side sends its own, the parent in the handoff and the
+// daemon, which settles on the lower of the two, in its startup report.
+// Bump it when a change needs both ends to know of it; additions that an
+// older peer can safely ignore, such as a new field or message type, do not.
+const protocolVersion = 1
+
+// negotiateVersion returns the version to speak with a peer that sent
+// peer. A peer that sent none predates versioning and speaks version 1.
+func negotiateVersion(peer int) int {
+	return min(max(peer, 1), protocolVersion)
+}
+
+// ProtocolVersion returns the protocol version the parent and the daemon
+// agreed on in the handshake, the lower of the two ends' versions, so that
+// code can check for a feature that needs a newer peer. It is 0 until the
+// daemon has reported to the parent, or until WaitForParent in the daemon.
+// Called by either process.
+func (d *Daemon) ProtocolVersion() int {
+	return d.version
+}
+
+// TransportUsed returns the transport the handshake actually went through:
+// "pipe", "memfd", "socketpair", or "file" for Config.ParamFile. It shows
+// whether a requested transport fell back to pipes. It is empty until
+// Daemonize has opened the pipes, or until WaitForParent in the daemon.
+// Called by either process.
+func (d *Daemon) TransportUsed() string {
+	return d.transport
+}
+
+// transportOf infers the transport from the param fd the daemon inherited.
+func transportOf(f *os.File) string {
+	fi, err := f.Stat()
+	switch {
+	case err != nil:
+		return ""
+	case fi.Mode()&os.ModeSocket != 0:
+		return transportSocketpair
+	case !fi.Mode().IsRegular():
+		return transportPipe
+	}
+	// a memfd is a regular file, told apart by its name
+	link, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", fdOf(f)))
+	if err == nil && strings.HasPrefix(link, "/memfd:") {
+		return transportMemfd
+	}
+	return transportFile
+}
+
+// fileParams writes payload to path, readable by the owner only, and opens
+// it for the daemon to inherit as its param fd. The daemon reads the open
+// file rather than the path, so removing the path early cannot race it.
+func fileParams(path string, payload []byte) (*os.File, error) {
+	w, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("create param file: %w", err)
+	}
+	// O_CREATE leaves the mode of an existing file alone
+	if err := w.Chmod(0o600); err != nil {
+		w.Close()
+		return nil, fmt.Errorf("create param file: %w", err)
+	}
+	if _, err := w.Write(payload); err != nil {
+		w.Close()
+		return nil, fmt.Errorf("write param file: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("write param file: %w", err)
+	}
+
+	r, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open param file: %w", err)
+	}
+	return r, nil
+}
+
+// removeParamFile removes Config.ParamFile once the daemon holds it open,
+// unless Config.KeepParamFile keeps it for auditing.
+func removeParamFile(cfg *Config) {
+	if cfg == nil || cfg.ParamFile == "" || cfg.KeepParamFile {
+		return
+	}
+	os.Remove(cfg.ParamFile)
+}
+
+func closeIfOpen(f *os.File) {
+	if f != nil {
+		f.Close()
+	}
+}
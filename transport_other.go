@@ -0,0 +1,12 @@
+//go:build !linux
+
+package daemonizer
+
+import (
+	"errors"
+	"os"
+)
+
+func memfdParams(payload []byte) (*os.File, error) {
+	return nil, errors.New("memfd is only supported on Linux")
+}
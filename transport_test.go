@@ -0,0 +1,77 @@
+//go:build unix
+
+package daemonizer_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+// TestPipeFdLimit checks that running out of fds between the param and the
+// status pipe fails Daemonize with a hint at the fd limit, and closes the
+// param pipe.
+func TestPipeFdLimit(t *testing.T) {
+	before := openFds(t)
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skipf("cannot list open fds: %v", err)
+	}
+	top := 0
+	for _, e := range entries {
+		if fd, err := strconv.Atoi(e.Name()); err == nil {
+			top = max(top, fd)
+		}
+	}
+	// fill the gaps below top, so that exactly the two fds of the param
+	// pipe fit under the limit
+	var fillers []*os.File
+	defer func() {
+		for _, f := range fillers {
+			f.Close()
+		}
+	}()
+	for {
+		f, err := os.Open(os.DevNull)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int(f.Fd()) > top {
+			f.Close()
+			break
+		}
+		fillers = append(fillers, f)
+	}
+
+	var old syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &old); err != nil {
+		t.Fatal(err)
+	}
+	limit := old
+	limit.Cur = uint64(top + 3)
+	if err := syscall.Setrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		t.Skipf("cannot lower the fd limit: %v", err)
+	}
+	err = godaemonizer.New(godaemonizer.WithName("serve")).Daemonize(context.Background(), nil, nil)
+	if err := syscall.Setrlimit(syscall.RLIMIT_NOFILE, &old); err != nil {
+		t.Fatal(err)
+	}
+
+	if !errors.Is(err, syscall.EMFILE) || !strings.Contains(err.Error(), "status pipe") || !strings.Contains(err.Error(), "open file limit") {
+		t.Errorf("Daemonize: %v, want EMFILE creating the status pipe, with a hint at the limit", err)
+	}
+	for _, f := range fillers {
+		f.Close()
+	}
+	fillers = nil
+	if after := openFds(t); !slices.Equal(after, before) {
+		t.Errorf("open fds after the failure: %v, want %v", after, before)
+	}
+}
@@ -0,0 +1,31 @@
+package daemonizer
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// setNamespaces has the daemon start in the fresh namespaces of
+// Config.Unshare. A mount namespace is unshared after the clone rather than
+// cloned, so that exec first makes all mounts private: with the usual
+// shared propagation, the daemon's mounts would show up on the host.
+func setNamespaces(attr *syscall.SysProcAttr, flags uintptr) {
+	attr.Cloneflags |= flags &^ syscall.CLONE_NEWNS
+	attr.Unshareflags |= flags & syscall.CLONE_NEWNS
+}
+
+// ownPIDNamespace reports whether flags give the daemon a PID namespace
+// of its own, where its PIDs mean nothing to the parent.
+func ownPIDNamespace(flags uintptr) bool {
+	return flags&syscall.CLONE_NEWPID != 0
+}
+
+// namespaceError adds a hint to a failed start that asked for namespaces
+// without the privileges they need.
+func namespaceError(flags uintptr, err error) error {
+	if flags == 0 || !errors.Is(err, syscall.EPERM) {
+		return err
+	}
+	return fmt.Errorf("%w (Config.Unshare needs CAP_SYS_ADMIN, or CLONE_NEWUSER with SysProcAttr.UidMappings)", err)
+}
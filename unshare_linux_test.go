@@ -0,0 +1,49 @@
+package daemonizer_test
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+func init() {
+	roles["mount-ns"] = func(d *godaemonizer.Daemon) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		ns, err := os.Readlink("/proc/self/ns/mnt")
+		if err != nil {
+			ready(err)
+			return
+		}
+		d.SetReadyData(ns)
+		ready(nil)
+	}
+}
+
+// TestUnshareMount checks that a daemon started with Unshare set to
+// CLONE_NEWNS has a mount namespace other than the parent's. It needs root.
+func TestUnshareMount(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("unsharing the mount namespace needs root")
+	}
+	parent, err := os.Readlink("/proc/self/ns/mnt")
+	if err != nil {
+		t.Skipf("cannot read the mount namespace: %v", err)
+	}
+
+	d := godaemonizer.New(godaemonizer.WithName("mount-ns"))
+	t.Cleanup(func() { d.Kill() })
+	var ns string
+	cfg := &godaemonizer.Config{Unshare: syscall.CLONE_NEWNS}
+	if err := d.DaemonizeWithData(context.Background(), nil, cfg, &ns); err != nil {
+		t.Fatalf("Daemonize: %v", err)
+	}
+	if ns == parent {
+		t.Errorf("daemon in the parent's mount namespace %s", ns)
+	}
+}
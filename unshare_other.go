@@ -0,0 +1,15 @@
+//go:build !linux
+
+package daemonizer
+
+import "syscall"
+
+func setNamespaces(attr *syscall.SysProcAttr, flags uintptr) {}
+
+func ownPIDNamespace(flags uintptr) bool {
+	return false
+}
+
+func namespaceError(flags uintptr, err error) error {
+	return err
+}
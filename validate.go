@@ -0,0 +1,127 @@
+package daemonizer
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+// Validate reports settings that contradict each other or can never work,
+// e.g. ParamSourceStdin together with Stdin, listing every problem found,
+// joined with errors.Join. Daemonize and DryRun call it before doing
+// anything else; call it directly to check a Config early, such as right
+// after loading it. A nil Config is valid.
+func (c *Config) Validate() error {
+	if c == nil {
+		return nil
+	}
+
+	var errs []error
+	if c.ParamSource == ParamSourceStdin && (c.Stdin != nil || c.StdinData != nil) {
+		errs = append(errs, errStdinInUse)
+	}
+	if c.PromptBeforeDetach && (c.Stdin != nil || c.StdinData != nil || c.ParamSource == ParamSourceStdin) {
+		errs = append(errs, errors.New("Config.PromptBeforeDetach needs the daemon's stdin for the terminal"))
+	}
+	if c.PromptBeforeDetach && c.TailOutput != nil {
+		errs = append(errs, errors.New("Config.PromptBeforeDetach and Config.TailOutput are exclusive"))
+	}
+	if c.Stdin != nil && c.StdinData != nil {
+		errs = append(errs, errors.New("Config.Stdin and Config.StdinData are exclusive"))
+	}
+	if c.ParamFile != "" && c.Control {
+		errs = append(errs, errParamFileControl)
+	}
+	if c.ParamFile != "" && c.Secrets != nil {
+		errs = append(errs, errParamFileSecrets)
+	}
+	if c.Stdout != nil && c.StdoutPath != "" {
+		errs = append(errs, errors.New("Config.Stdout and Config.StdoutPath are exclusive"))
+	}
+	if c.Stderr != nil && c.StderrPath != "" {
+		errs = append(errs, errors.New("Config.Stderr and Config.StderrPath are exclusive"))
+	}
+	if c.TailOutput != nil && c.TailDuration <= 0 {
+		errs = append(errs, errors.New("Config.TailOutput needs a positive Config.TailDuration"))
+	}
+	if c.TailDuration != 0 && c.TailOutput == nil {
+		errs = append(errs, errors.New("Config.TailDuration is set without Config.TailOutput"))
+	}
+	if c.TransferState && !c.Control && c.ControlSocket == "" {
+		errs = append(errs, errors.New("Config.TransferState needs Config.Control or Config.ControlSocket"))
+	}
+	if c.KeepParamFile && c.ParamFile == "" {
+		errs = append(errs, errors.New("Config.KeepParamFile needs Config.ParamFile"))
+	}
+	if c.CreateDir && c.Dir == "" {
+		errs = append(errs, errors.New("Config.CreateDir needs Config.Dir"))
+	}
+	if c.DirMode != 0 && !c.CreateDir {
+		errs = append(errs, errors.New("Config.DirMode only applies with Config.CreateDir"))
+	}
+	if len(c.WrapperCommand) > 0 && c.WrapperCommand[0] == "" {
+		errs = append(errs, errors.New("Config.WrapperCommand has no program"))
+	}
+	if c.OOMScoreAdj != nil && (*c.OOMScoreAdj < -1000 || *c.OOMScoreAdj > 1000) {
+		errs = append(errs, fmt.Errorf("Config.OOMScoreAdj %d outside -1000..1000", *c.OOMScoreAdj))
+	}
+	if c.StatusTypes != nil {
+		if err := c.StatusTypes.validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.StartTimeout < 0 {
+		errs = append(errs, fmt.Errorf("Config.StartTimeout %v is negative", c.StartTimeout))
+	}
+	if c.CancelGrace < 0 {
+		errs = append(errs, fmt.Errorf("Config.CancelGrace %v is negative", c.CancelGrace))
+	}
+	if c.GOMAXPROCS < 0 {
+		errs = append(errs, fmt.Errorf("Config.GOMAXPROCS %d is negative", c.GOMAXPROCS))
+	}
+	if c.MaxThreads < 0 {
+		errs = append(errs, fmt.Errorf("Config.MaxThreads %d is negative", c.MaxThreads))
+	}
+	if c.ParentPollInterval < 0 {
+		errs = append(errs, fmt.Errorf("Config.ParentPollInterval %v is negative", c.ParentPollInterval))
+	}
+	if c.ParentPollInterval != 0 && !c.DieWithParent {
+		errs = append(errs, errors.New("Config.ParentPollInterval is set without Config.DieWithParent"))
+	}
+	if c.StartupDeadline < 0 {
+		errs = append(errs, fmt.Errorf("Config.StartupDeadline %v is negative", c.StartupDeadline))
+	}
+	if c.StatusFileInterval < 0 {
+		errs = append(errs, fmt.Errorf("Config.StatusFileInterval %v is negative", c.StatusFileInterval))
+	}
+	if c.DoubleFork && runtime.GOOS == "windows" {
+		errs = append(errs, errors.New("Config.DoubleFork is not supported on Windows"))
+	}
+	if c.DoubleFork && c.DieWithParent {
+		errs = append(errs, errors.New("Config.DoubleFork and Config.DieWithParent are exclusive"))
+	}
+	if c.AllocatePTY && runtime.GOOS != "linux" {
+		errs = append(errs, errors.New("Config.AllocatePTY is only supported on Linux"))
+	}
+	if c.AllocatePTY && (c.Stdin != nil || c.StdinData != nil || c.Stdout != nil || c.Stderr != nil ||
+		c.StdoutPath != "" || c.StderrPath != "" || c.TailOutput != nil) {
+		errs = append(errs, errors.New("Config.AllocatePTY takes the daemon's stdio, which another setting also sets"))
+	}
+	if c.AllocatePTY && (c.PromptBeforeDetach || c.ParamSource == ParamSourceStdin) {
+		errs = append(errs, errors.New("Config.AllocatePTY needs the daemon's stdin for the terminal"))
+	}
+	// the byte may end the handshake before the report, which these need
+	if c.ReadinessViaFd && (c.Control || c.KeepOutputOpen || c.DoubleFork) {
+		errs = append(errs, errors.New("Config.ReadinessViaFd cannot be combined with Control, KeepOutputOpen or DoubleFork"))
+	}
+	if c.Unshare != 0 && runtime.GOOS != "linux" {
+		errs = append(errs, errors.New("Config.Unshare is only supported on Linux"))
+	}
+	if ownPIDNamespace(c.Unshare) && (c.PIDFile != "" || c.DoubleFork || c.DieWithParent) {
+		errs = append(errs, errors.New("Config.Unshare with CLONE_NEWPID rules out Config.PIDFile, DoubleFork and DieWithParent, which rely on the daemon's pids"))
+	}
+	if c.SpawnRetries < 0 {
+		errs = append(errs, fmt.Errorf("Config.SpawnRetries %d is negative", c.SpawnRetries))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,108 @@
+package daemonizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Params is a generically decoded params object for daemons that do not
+// decode into a struct. Numbers are kept as json.Number so integers survive
+// exactly. The accessors return def when a key is missing or holds a value
+// of the wrong type.
+type Params map[string]any
+
+// ParamsMap decodes the params received from the parent into a Params.
+// Called by the daemon process after WaitForParent.
+func (d *Daemon) ParamsMap() (Params, error) {
+	if d.rawParams == nil {
+		return nil, ErrNotStarted
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(d.rawParams))
+	dec.UseNumber()
+
+	var p Params
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("decode params: %w", err)
+	}
+	return p, nil
+}
+
+// DecodeParams decodes the params received from the parent into dst, a
+// pointer to a struct or any other type encoding/json can fill, honoring
+// json tags. It decodes the params as the parent serialized them, not from
+// a map, so numbers land in their fields' types without passing through
+// float64. It suits large configs better than the Params accessors, and can
+// be called several times, e.g. to decode parts of the params into
+// different types. Called by the daemon process after WaitForParent.
+func (d *Daemon) DecodeParams(dst any) error {
+	if d.rawParams == nil {
+		return ErrNotStarted
+	}
+	if err := json.Unmarshal(d.rawParams, dst); err != nil {
+		return fmt.Errorf("decode params: %w", err)
+	}
+	return nil
+}
+
+// String returns the string value of key.
+func (p Params) String(key, def string) string {
+	if s, ok := p[key].(string); ok {
+		return s
+	}
+	return def
+}
+
+// Int returns the integer value of key. Numbers with a fractional part or
+// outside the int range count as the wrong type.
+func (p Params) Int(key string, def int) int {
+	switch v := p[key].(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil && n >= math.MinInt && n <= math.MaxInt {
+			return int(n)
+		}
+	case float64:
+		// MaxInt rounds up to MaxInt+1 as a float64, so compare with
+		// that, which is exact
+		if v == math.Trunc(v) && v >= math.MinInt && v < -math.MinInt {
+			return int(v)
+		}
+	case int:
+		return v
+	}
+	return def
+}
+
+// Bool returns the boolean value of key.
+func (p Params) Bool(key string, def bool) bool {
+	if b, ok := p[key].(bool); ok {
+		return b
+	}
+	return def
+}
+
+// Duration returns the duration value of key, given either as a string
+// accepted by time.ParseDuration (e.g. "30s") or as an integer number of
+// nanoseconds, which is how encoding/json serializes a time.Duration.
+func (p Params) Duration(key string, def time.Duration) time.Duration {
+	switch v := p[key].(type) {
+	case string:
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return time.Duration(n)
+		}
+	case float64:
+		if v == math.Trunc(v) && v >= math.MinInt64 && v < -math.MinInt64 {
+			return time.Duration(v)
+		}
+	case time.Duration:
+		return v
+	}
+	return def
+}
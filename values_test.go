@@ -0,0 +1,92 @@
+package daemonizer_test
+
+import (
+	"context"
+	"math"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	godaemonizer "github.com/cyverse/go-daemonizer"
+)
+
+// decodedParams is what the "decode-params" daemon decodes its params into.
+type decodedParams struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Verbose bool   `json:"verbose"`
+	Server  struct {
+		Port  int      `json:"port"`
+		Hosts []string `json:"hosts"`
+	} `json:"server"`
+}
+
+func init() {
+	roles["decode-params"] = func(d *godaemonizer.Daemon) {
+		ready, err := d.WaitForParent(nil)
+		if err != nil {
+			os.Exit(1)
+		}
+		var p decodedParams
+		if err := d.DecodeParams(&p); err != nil {
+			ready(err)
+			return
+		}
+		d.SetReadyData(p)
+		ready(nil)
+	}
+}
+
+// TestDecodeParams checks that DecodeParams fills a struct by its json
+// tags, nested fields included, keeping integers too large for a float64
+// exact.
+func TestDecodeParams(t *testing.T) {
+	var want decodedParams
+	want.ID, want.Name, want.Verbose = 1<<53+1, "worker", true
+	want.Server.Port, want.Server.Hosts = 8080, []string{"a", "b"}
+	params := map[string]any{
+		"id":      want.ID,
+		"name":    want.Name,
+		"verbose": want.Verbose,
+		"server":  map[string]any{"port": want.Server.Port, "hosts": want.Server.Hosts},
+	}
+
+	d := godaemonizer.New(godaemonizer.WithName("decode-params"))
+	t.Cleanup(func() { d.Kill() })
+	var got decodedParams
+	if err := d.DaemonizeWithData(context.Background(), params, nil, &got); err != nil {
+		t.Fatalf("Daemonize: %v", err)
+	}
+	if got.ID != want.ID || got.Name != want.Name || got.Verbose != want.Verbose ||
+		got.Server.Port != want.Server.Port || !slices.Equal(got.Server.Hosts, want.Server.Hosts) {
+		t.Errorf("daemon decoded %+v, want %+v", got, want)
+	}
+}
+
+// TestParamsIntRange checks the edges of the int range for numbers that
+// encoding/json decoded as float64.
+func TestParamsIntRange(t *testing.T) {
+	if math.MaxInt != math.MaxInt64 {
+		t.Skip("int is not 64 bits wide")
+	}
+	below := math.Nextafter(1<<63, 0) // the largest float64 that fits
+	for _, tc := range []struct {
+		value float64
+		want  int64
+	}{
+		{below, int64(below)},
+		{-1 << 63, math.MinInt64},
+		{1 << 63, -1},
+		{math.Nextafter(-1<<63, math.Inf(-1)), -1},
+		{1.5, -1},
+	} {
+		p := godaemonizer.Params{"n": tc.value}
+		if got := p.Int("n", -1); int64(got) != tc.want {
+			t.Errorf("Int(%v) = %d, want %d", tc.value, got, tc.want)
+		}
+		if got := p.Duration("n", -1); int64(got) != tc.want {
+			t.Errorf("Duration(%v) = %d, want %d", tc.value, got, time.Duration(tc.want))
+		}
+	}
+}